@@ -5,7 +5,7 @@ go 1.13
 require (
 	github.com/fatih/color v1.9.0
 	github.com/gorilla/handlers v1.4.2
-	github.com/gorilla/websocket v1.4.2 // indirect
+	github.com/gorilla/websocket v1.4.2
 	github.com/howeyc/gopass v0.0.0-20190910152052-7cb4b85ec19c
 	github.com/mangelajo/track v0.0.0
 	github.com/pkg/errors v0.9.1 // indirect
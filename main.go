@@ -7,26 +7,41 @@ import (
 	"io"
 	"log"
 	"os"
+	"os/signal"
+	"regexp"
 	"strings"
+	"syscall"
 	"time"
 
-	slackgo "github.com/slack-go/slack"
 	"github.com/spf13/pflag"
 	"k8s.io/klog"
 
 	"github.com/sttts/sttts-bot/bugzilla"
 	"github.com/sttts/sttts-bot/slacker"
+	"github.com/sttts/sttts-bot/store"
 )
 
 const Version = "0.0.1"
 
+// groupPattern matches the OpenShift group names used in the Bugzilla saved searches
+var groupPattern = regexp.MustCompile(`^[a-z0-9]+$`)
+
 type options struct {
 	GithubEndpoint string
-	Slack          slacker.Options
-	Bugzilla       bugzilla.Options
+	// REPL reads commands from the terminal instead of Slack
+	REPL bool
+	// Exec is a command to run in the terminal instead of listening to Slack
+	Exec     string
+	Slack    slacker.Options
+	Bugzilla bugzilla.Options
+	Store    store.Options
 }
 
 func Validate(opt *options) error {
+	if opt.REPL || len(opt.Exec) > 0 {
+		// no Slack credentials needed in the terminal
+		return slacker.ValidateCommandOptions(&opt.Slack)
+	}
 	return slacker.ValidateOptions(&opt.Slack)
 }
 
@@ -44,12 +59,26 @@ func run() error {
 	}
 
 	pflag.StringVar(&opt.GithubEndpoint, "github-endpoint", opt.GithubEndpoint, "An optional proxy for connecting to github.")
+	pflag.BoolVar(&opt.REPL, "repl", opt.REPL, "Read commands from the terminal and print the responses, without connecting to Slack.")
 	slacker.AddFlags(&opt.Slack)
 	bugzilla.AddBugzillaFlags(&opt.Bugzilla)
+	store.AddFlags(&opt.Store)
 	klog.InitFlags(flag.CommandLine)
 	pflag.CommandLine.AddGoFlag(flag.Lookup("v"))
 
+	pflag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] [exec <command>]\n", os.Args[0])
+		pflag.PrintDefaults()
+	}
 	pflag.Parse()
+	switch args := pflag.Args(); {
+	case len(args) == 0:
+	case args[0] == "exec" && len(args) > 1:
+		opt.Exec = joinArgs(args[1:])
+	default:
+		pflag.Usage()
+		os.Exit(2)
+	}
 
 	if err := Validate(opt); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v", err)
@@ -62,7 +91,24 @@ func run() error {
 	}
 	defer bz.Close()
 
+	st, err := store.NewStore(opt.Store)
+	if err != nil {
+		return err
+	}
+
+	local := opt.REPL || len(opt.Exec) > 0
 	slack := slacker.NewSlacker(opt.Slack)
+	if len(opt.Slack.RBACConfig) > 0 && !local {
+		config, err := slacker.LoadRBACConfig(opt.Slack.RBACConfig)
+		if err != nil {
+			return err
+		}
+		slack.RBAC(config)
+	}
+	if st != nil && !local {
+		slack.StateStore(st)
+	}
+	slack.Use(slacker.RecoverPanics(), slacker.LogLatency())
 	slack.Command("version", &slacker.CommandDefinition{
 		Description: "Report the version of the bot",
 		Handler: func(request slacker.Request, response slacker.ResponseWriter) {
@@ -71,55 +117,154 @@ func run() error {
 	})
 	slack.Command("say <message>", &slacker.CommandDefinition{
 		Description: "Say something.",
+		Example:     "say hello world",
+		Params:      []slacker.Param{{Name: "message", Description: "The text to repeat."}},
 		Handler: func(req slacker.Request, w slacker.ResponseWriter) {
 			msg := req.StringParam("message", "")
 			w.Reply(msg)
 		},
 	})
-	slack.Command("bz-stats", &slacker.CommandDefinition{
-		Description: "Show group B Bugzilla statistics.",
+	bzGroup := slack.Group("bz").Describe(&slacker.GroupDefinition{
+		Description: "Query the Red Hat Bugzilla.",
+		Category:    "Bugzilla",
+	})
+	bzGroup.Command("stats <group>", &slacker.CommandDefinition{
+		Description: "Show Bugzilla statistics of an OpenShift group.",
+		Example:     "bz stats b",
+		RootAliases: []string{"bz-stats", "stats"},
+		Params: []slacker.Param{
+			{Name: "group", Description: "The group letter, defaulting to the channel default or b."},
+		},
+		RateLimit:     &slacker.RateLimit{Burst: 2, Every: time.Minute},
+		Timeout:       5 * time.Minute,
+		ThreadReplies: true,
 		Handler: func(req slacker.Request, w slacker.ResponseWriter) {
+			group := strings.ToLower(strings.TrimSpace(req.StringParam("group", "b")))
+			if !groupPattern.MatchString(group) {
+				w.ReportError(fmt.Errorf("invalid group %q", group))
+				return
+			}
+			// the saved lists only exist for group B, other groups run the named queries
+			listID := func(id string) string {
+				if group == "b" {
+					return "&list_id=" + id
+				}
+				return ""
+			}
 			urls := map[string]string{
-				"blockers": "cmdtype=dorem&remaction=run&namedcmd=openshift-group-b-blockers&sharer_id=290313",
-				"customer": "cmdtype=dorem&list_id=11029281&namedcmd=openshift-group-b-customer&remaction=run&sharer_id=290313",
-				"priority": "cmdtype=dorem&list_id=11029283&namedcmd=openshift-group-b-prio&remaction=run&sharer_id=290313",
-				"triage":   "cmdtype=dorem&remaction=run&namedcmd=openshift-group-b-triage&sharer_id=290313",
-				"junk":     "cmdtype=dorem&remaction=run&namedcmd=openshift-group-b-junk&sharer_id=290313",
+				"blockers": fmt.Sprintf("cmdtype=dorem&remaction=run&namedcmd=openshift-group-%s-blockers&sharer_id=290313", group),
+				"customer": fmt.Sprintf("cmdtype=dorem%s&namedcmd=openshift-group-%s-customer&remaction=run&sharer_id=290313", listID("11029281"), group),
+				"priority": fmt.Sprintf("cmdtype=dorem%s&namedcmd=openshift-group-%s-prio&remaction=run&sharer_id=290313", listID("11029283"), group),
+				"triage":   fmt.Sprintf("cmdtype=dorem&remaction=run&namedcmd=openshift-group-%s-triage&sharer_id=290313", group),
+				"junk":     fmt.Sprintf("cmdtype=dorem&remaction=run&namedcmd=openshift-group-%s-junk&sharer_id=290313", group),
+			}
+			progress := w.Progress()
+			if err := progress.Update(fmt.Sprintf("Querying %d saved searches of group %s...", len(urls), strings.ToUpper(group))); err != nil {
+				klog.Error(err)
 			}
+
 			stats := map[string]int{}
 			for k, url := range urls {
-				_, _, _, err := w.Client().SendMessage(req.Event().Channel,
-					slackgo.MsgOptionPostEphemeral(req.Event().User),
-					slackgo.MsgOptionText(fmt.Sprintf("Querying %q...", url), false))
-				if err != nil {
-					klog.Error(err)
+				if err := req.Context().Err(); err != nil {
+					klog.Infof("Stopping bz stats for group %s: %v", group, err)
+					if err := progress.Fail(err); err != nil {
+						klog.Error(err)
+					}
+					return
 				}
 
 				bugs, err := bz.BugList(&bugzilla.BugListQuery{CustomQuery: url})
 				if err != nil {
-					_, _, _, err := w.Client().SendMessage(req.Event().Channel,
-						slackgo.MsgOptionPostEphemeral(req.Event().User),
-						slackgo.MsgOptionText(fmt.Sprintf("failed to query bug list %q: %v", url, err), false))
-					if err != nil {
+					if err := progress.Fail(fmt.Errorf("failed to query bug list %q: %v", url, err)); err != nil {
 						klog.Error(err)
 					}
 					return
 				}
 
 				stats[k] = len(bugs)
+				if err := progress.Update(fmt.Sprintf("%d/%d queries done...", len(stats), len(urls))); err != nil {
+					klog.Error(err)
+				}
+			}
+
+			// the short links only exist for group B
+			links := map[string]string{}
+			if group == "b" {
+				links = map[string]string{
+					"blockers": " (https://red.ht/2KJlqiO)",
+					"customer": " (https://red.ht/2VNOuvQ)",
+					"priority": " (https://red.ht/2Ym0CWG)",
+					"triage":   " (https://red.ht/3d0yOLj)",
+					"junk":     " (https://red.ht/2VQ9TEz)",
+				}
 			}
 
 			//msg := request.StringParam("message", "")
-			if err := w.Reply(fmt.Sprintf(`Blockers Bugs Total (https://red.ht/2KJlqiO)
+			if err := progress.Done(fmt.Sprintf(`Group %s
+Blockers Bugs Total%s
 %d
-Bugs With Customer Case (https://red.ht/2VNOuvQ)
+Bugs With Customer Case%s
 %d
-Priority Bugs (https://red.ht/2Ym0CWG)
+Priority Bugs%s
 %d
-Bugs To Triage (https://red.ht/3d0yOLj)
+Bugs To Triage%s
 %d
-Junk Bugs (https://red.ht/2VQ9TEz)
-%d`, stats["blockers"], stats["customer"], stats["priority"], stats["triage"], stats["junk"])); err != nil {
+Junk Bugs%s
+%d`, strings.ToUpper(group),
+				links["blockers"], stats["blockers"],
+				links["customer"], stats["customer"],
+				links["priority"], stats["priority"],
+				links["triage"], stats["triage"],
+				links["junk"], stats["junk"])); err != nil {
+				klog.Error(err)
+			}
+		},
+	})
+	bzGroup.Command("query", &slacker.CommandDefinition{
+		Description: "List Bugzilla bugs matching the options.",
+		Example:     `bz query --status NEW --status ASSIGNED component="kube-apiserver"`,
+		RootAliases: []string{"bz-query"},
+		Flags: []slacker.Param{
+			{Name: "product", Description: "The product, defaulting to OpenShift Container Platform."},
+			{Name: "component", Description: "The component."},
+			{Name: "status", Description: "Bug states, all open ones by default.", Type: slacker.ParamList, Values: []string{"NEW", "ASSIGNED", "POST", "MODIFIED", "ON_DEV", "ON_QA", "VERIFIED", "RELEASE_PENDING", "CLOSED"}},
+			{Name: "assignee", Description: "The e-mail address of the assignee.", Type: slacker.ParamEmail},
+			{Name: "limit", Description: "The maximal number of bugs to list, 20 by default.", Type: slacker.ParamInt},
+		},
+		RateLimit:     &slacker.RateLimit{Burst: 5, Every: time.Minute},
+		Timeout:       2 * time.Minute,
+		ThreadReplies: true,
+		Handler: func(req slacker.Request, w slacker.ResponseWriter) {
+			status := req.ListParam("status")
+			if len(status) == 0 {
+				status = []string{"NEW", "ASSIGNED", "POST", "ON_DEV"}
+			}
+			limit := req.IntegerParam("limit", 20)
+			bugs, err := bz.BugList(&bugzilla.BugListQuery{
+				Product:    req.StringParam("product", "OpenShift Container Platform"),
+				Component:  req.Param("component"),
+				BugStatus:  status,
+				AssignedTo: req.Param("assignee"),
+				Limit:      limit,
+			})
+			if err != nil {
+				w.ReportError(fmt.Errorf("failed to query bug list: %v", err))
+				return
+			}
+			if len(bugs) == 0 {
+				w.Reply("No bugs found.")
+				return
+			}
+
+			lines := []string{fmt.Sprintf("%d bugs:", len(bugs))}
+			for i, bug := range bugs {
+				if i >= limit {
+					lines = append(lines, "...")
+					break
+				}
+				lines = append(lines, fmt.Sprintf("<%s|%d> %s %s - %s", bug.URL, bug.ID, bug.Status, bug.Component, bug.Subject))
+			}
+			if _, err := w.Reply(strings.Join(lines, "\n")); err != nil {
 				klog.Error(err)
 			}
 		},
@@ -128,12 +273,51 @@ Junk Bugs (https://red.ht/2VQ9TEz)
 		w.Reply("Unknown command")
 	})
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		sig := <-signals
+		klog.Infof("Received %v, draining running commands", sig)
+		cancel()
+	}()
+
+	switch {
+	case len(opt.Exec) > 0:
+		return slack.Exec(ctx, slacker.TerminalUser, opt.Exec, os.Stdout)
+	case opt.REPL:
+		return slack.REPL(ctx, slacker.TerminalUser, os.Stdin, os.Stdout)
+	}
+
+	slack.ServeMetrics(ctx)
 	for {
-		if err := slack.Listen(context.Background()); err != nil && !isRetriable(err) {
+		err := slack.Listen(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil && !isRetriable(err) {
 			return err
 		}
-		time.Sleep(5 * time.Second)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// joinArgs joins the command line arguments to a command text, quoting those the shell unquoted.
+func joinArgs(args []string) string {
+	words := make([]string, 0, len(args))
+	for _, arg := range args {
+		if strings.ContainsAny(arg, " \t") && !strings.Contains(arg, `"`) {
+			arg = `"` + arg + `"`
+		}
+		words = append(words, arg)
 	}
+	return strings.Join(words, " ")
 }
 
 func isRetriable(err error) bool {
@@ -0,0 +1,161 @@
+package slacker
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack/slackevents"
+	"k8s.io/klog"
+)
+
+const (
+	// threads the bot took part in are remembered this long and up to this many
+	threadTTL      = 24 * time.Hour
+	maxThreadCount = 1000
+)
+
+// ignoredMessageSubTypes are message events which are no commands typed by a user,
+// see https://api.slack.com/events/message#subtypes
+var ignoredMessageSubTypes = map[string]bool{
+	"bot_message":       true,
+	"message_changed":   true,
+	"message_deleted":   true,
+	"message_replied":   true,
+	"channel_join":      true,
+	"channel_leave":     true,
+	"channel_topic":     true,
+	"channel_purpose":   true,
+	"channel_name":      true,
+	"group_join":        true,
+	"group_leave":       true,
+	"pinned_item":       true,
+	"unpinned_item":     true,
+	"ekm_access_denied": true,
+}
+
+// anyMentionPrefix matches a leading user mention, e.g. "<@U012AB3CD> " or "<@U012AB3CD|bot>: "
+var anyMentionPrefix = regexp.MustCompile(`^\s*<@[UW][A-Z0-9]+(\|[^>]*)?>[:,]?\s*`)
+
+// AddressingPolicy decides where the bot must be mentioned to treat a message as command
+type AddressingPolicy struct {
+	RequireMentionInChannels bool
+	RequireMentionInDMs      bool
+	RequireMentionInThreads  bool
+}
+
+// threadTracker remembers the threads the bot takes part in.
+type threadTracker struct {
+	lock    sync.Mutex
+	threads map[string]time.Time
+}
+
+func newThreadTracker() *threadTracker {
+	return &threadTracker{threads: map[string]time.Time{}}
+}
+
+func (t *threadTracker) add(channel, threadTimeStamp string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := time.Now()
+	t.threads[channel+"/"+threadTimeStamp] = now
+	if len(t.threads) > maxThreadCount {
+		for key, when := range t.threads {
+			if now.Sub(when) > threadTTL {
+				delete(t.threads, key)
+			}
+		}
+	}
+}
+
+func (t *threadTracker) has(channel, threadTimeStamp string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	when, ok := t.threads[channel+"/"+threadTimeStamp]
+	return ok && time.Since(when) <= threadTTL
+}
+
+// mentionMessage converts an app mention into a message event with the mention stripped.
+func (s *Slacker) mentionMessage(ev *slackevents.AppMentionEvent) *slackevents.MessageEvent {
+	return &slackevents.MessageEvent{
+		Type:            ev.Type,
+		User:            ev.User,
+		Text:            s.stripMention(ev.Text),
+		TimeStamp:       ev.TimeStamp,
+		ThreadTimeStamp: ev.ThreadTimeStamp,
+		Channel:         ev.Channel,
+		EventTimeStamp:  ev.EventTimeStamp,
+		UserTeam:        ev.UserTeam,
+		SourceTeam:      ev.SourceTeam,
+	}
+}
+
+// addressed decides by the addressing policy whether a plain message event is
+// meant for the bot, and strips the mention of the bot if so. Mentions outside
+// of DMs are skipped because they arrive as app_mention event as well. Messages
+// without mention are only meant for the bot if they start with a command, so
+// that the bot does not answer the chatter in DMs and in its threads.
+func (s *Slacker) addressed(ev *slackevents.MessageEvent) bool {
+	if len(ev.BotID) > 0 || ignoredMessageSubTypes[ev.SubType] || ev.User == s.botUserID || ev.User == slackBotUser {
+		return false
+	}
+
+	isDM := strings.HasPrefix(ev.Channel, directChannelMarker)
+	mentioned := s.mentionsBot(ev.Text)
+	switch {
+	case isDM:
+		if s.addressing.RequireMentionInDMs && !mentioned {
+			return false
+		}
+	case mentioned:
+		klog.V(4).Infof("Skipping message event mentioning the bot, handled as app_mention")
+		return false
+	case len(ev.ThreadTimeStamp) > 0 && s.threads.has(ev.Channel, ev.ThreadTimeStamp):
+		if s.addressing.RequireMentionInThreads {
+			return false
+		}
+	default:
+		if s.addressing.RequireMentionInChannels {
+			return false
+		}
+	}
+
+	ev.Text = s.stripMention(ev.Text)
+	return mentioned || s.startsWithCommand(ev.Text)
+}
+
+// startsWithCommand returns true if the text is a command or a group name.
+func (s *Slacker) startsWithCommand(text string) bool {
+	return s.findGroup(text) != nil || s.matchCommand(text) != nil
+}
+
+// participate remembers the thread of the message, and the thread replies to it would start.
+func (s *Slacker) participate(message *slackevents.MessageEvent) {
+	if strings.HasPrefix(message.Channel, directChannelMarker) {
+		return
+	}
+	if len(message.ThreadTimeStamp) > 0 {
+		s.threads.add(message.Channel, message.ThreadTimeStamp)
+	} else if len(message.TimeStamp) > 0 {
+		s.threads.add(message.Channel, message.TimeStamp)
+	}
+}
+
+func (s *Slacker) mentionsBot(text string) bool {
+	return len(s.botUserID) > 0 && strings.Contains(text, "<@"+s.botUserID)
+}
+
+// stripMention removes a leading mention of the bot, or any leading mention if the bot user is unknown.
+func (s *Slacker) stripMention(text string) string {
+	match := anyMentionPrefix.FindString(text)
+	if len(match) == 0 {
+		return strings.TrimSpace(text)
+	}
+	if len(s.botUserID) > 0 && !strings.Contains(match, "<@"+s.botUserID) {
+		return strings.TrimSpace(text)
+	}
+	return strings.TrimSpace(text[len(match):])
+}
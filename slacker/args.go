@@ -0,0 +1,276 @@
+package slacker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/shomali11/commander"
+	"github.com/shomali11/proper"
+)
+
+const (
+	flagPrefix = "--"
+	trueValue  = "true"
+
+	unknownFlagFormat    = "unknown option `%s`, expected one of %s. Usage: %s"
+	unexpectedArgsFormat = "unexpected `%s`, the command takes no more arguments. Usage: %s"
+)
+
+// quotes maps opening to closing quotes, including the typographic ones Slack
+// clients put in when auto-correction is on.
+var quotes = map[rune]rune{
+	'"':  '"',
+	'\'': '\'',
+	'“':  '”',
+	'‘':  '’',
+}
+
+// argToken is a word of the text. Quoted words are kept together, without the quotes.
+type argToken struct {
+	text string
+	// start is the offset of the token in the text
+	start  int
+	quoted bool
+}
+
+// tokenize splits the text at white space, keeping quoted parts together
+// without the quotes. Quotes only open at the start of a word or after the "=" of
+// key="value", so that apostrophes like in "don't" are kept. An unterminated quote runs to the end of the text.
+func tokenize(text string) []argToken {
+	var tokens []argToken
+	var token strings.Builder
+	inToken, quoted := false, false
+	start := 0
+	var closing rune
+	for i, r := range text {
+		switch {
+		case closing != 0:
+			if r == closing {
+				closing = 0
+			} else {
+				token.WriteRune(r)
+			}
+		case quotes[r] != 0 && (!inToken || strings.HasSuffix(token.String(), "=")):
+			closing = quotes[r]
+			if !inToken {
+				inToken, start = true, i
+			}
+			quoted = true
+		case unicode.IsSpace(r):
+			if inToken {
+				tokens = append(tokens, argToken{text: token.String(), start: start, quoted: quoted})
+				token.Reset()
+				inToken, quoted = false, false
+			}
+		default:
+			if !inToken {
+				inToken, start = true, i
+			}
+			token.WriteRune(r)
+		}
+	}
+	if inToken {
+		tokens = append(tokens, argToken{text: token.String(), start: start, quoted: quoted})
+	}
+	return tokens
+}
+
+// splitFlags separates "--key value", "--key=value", "key=value" and "--switch"
+// from the positional words. Keys are lower case, switches get the value "true".
+func splitFlags(tokens []argToken) ([]argToken, map[string][]string) {
+	var positional []argToken
+	flags := map[string][]string{}
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i].text
+		switch {
+		case strings.HasPrefix(token, flagPrefix) && len(token) > len(flagPrefix):
+			key := strings.TrimPrefix(token, flagPrefix)
+			if eq := strings.Index(key, "="); eq >= 0 {
+				flags[strings.ToLower(key[:eq])] = append(flags[strings.ToLower(key[:eq])], key[eq+1:])
+			} else if i+1 < len(tokens) && !isFlag(tokens[i+1].text) {
+				flags[strings.ToLower(key)] = append(flags[strings.ToLower(key)], tokens[i+1].text)
+				i++
+			} else {
+				flags[strings.ToLower(key)] = append(flags[strings.ToLower(key)], trueValue)
+			}
+		case isKeyValue(token):
+			eq := strings.Index(token, "=")
+			flags[strings.ToLower(token[:eq])] = append(flags[strings.ToLower(token[:eq])], token[eq+1:])
+		default:
+			positional = append(positional, tokens[i])
+		}
+	}
+	return positional, flags
+}
+
+func isFlag(token string) bool {
+	return strings.HasPrefix(token, flagPrefix) || isKeyValue(token)
+}
+
+// isKeyValue returns true for tokens like component=etcd, but not for URLs or mentions.
+func isKeyValue(token string) bool {
+	eq := strings.Index(token, "=")
+	if eq <= 0 {
+		return false
+	}
+	for _, r := range token[:eq] {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// commandMatch is a command matched against a text.
+type commandMatch struct {
+	command    BotCommand
+	parameters *proper.Properties
+	flags      map[string][]string
+	// extra are the words left over after the parameters
+	extra []string
+	// words is the number of words of the matched name or alias
+	words int
+}
+
+// matchCommand matches the text against the command, from the beginning of the
+// text. For commands with Flags, the flags are taken out of the text first and
+// returned separately. It returns nil if the command does not match.
+func matchCommand(command BotCommand, text string) *commandMatch {
+	c, ok := command.(*botCommand)
+	if !ok {
+		// other implementations match on their own
+		parameters, ok := command.Match(text)
+		if !ok {
+			return nil
+		}
+		return &commandMatch{command: command, parameters: parameters, words: len(strings.Fields(commandName(command)))}
+	}
+
+	tokens := tokenize(text)
+	var flags map[string][]string
+	if c.definition != nil && len(c.definition.Flags) > 0 {
+		// free text is joined from the positional words, the flags are in between
+		tokens, flags = splitFlags(tokens)
+		text = empty
+	}
+	m := c.match(tokens, text)
+	if m == nil {
+		return nil
+	}
+	m.command, m.flags = command, flags
+	return m
+}
+
+// matchUsage matches the tokens against the usage of a command: the leading words
+// case-insensitively, then one token per parameter. The last parameter takes all
+// remaining tokens if it is free text, verbatim from the text if none of them is
+// quoted. Missing parameters stay empty, validateParams checks the required ones.
+func matchUsage(usage []*commander.Token, tokens []argToken, text string, definition *CommandDefinition) *commandMatch {
+	m := &commandMatch{}
+	parameters := map[string]string{}
+	i := 0
+	for k, token := range usage {
+		switch {
+		case !token.IsParameter():
+			if i >= len(tokens) || !strings.EqualFold(tokens[i].text, token.Word) {
+				return nil
+			}
+			if len(parameters) == 0 {
+				m.words++
+			}
+			i++
+		case i >= len(tokens):
+		case k == len(usage)-1 && isFreeText(definition, token.Word) && i < len(tokens)-1:
+			if len(text) > 0 && !anyQuoted(tokens[i:]) {
+				parameters[token.Word] = strings.TrimSpace(text[tokens[i].start:])
+			} else {
+				var words []string
+				for _, t := range tokens[i:] {
+					words = append(words, t.text)
+				}
+				parameters[token.Word] = strings.Join(words, space)
+			}
+			i = len(tokens)
+		default:
+			parameters[token.Word] = tokens[i].text
+			i++
+		}
+	}
+	for ; i < len(tokens); i++ {
+		m.extra = append(m.extra, tokens[i].text)
+	}
+	m.parameters = proper.NewProperties(parameters)
+	return m
+}
+
+func anyQuoted(tokens []argToken) bool {
+	for _, t := range tokens {
+		if t.quoted {
+			return true
+		}
+	}
+	return false
+}
+
+// isFreeText returns true if the parameter takes any text, i.e. it is a string or a list.
+func isFreeText(definition *CommandDefinition, name string) bool {
+	if definition != nil {
+		for _, p := range definition.Params {
+			if p.Name == name {
+				return p.Type == ParamString || p.Type == ParamList
+			}
+		}
+	}
+	return true
+}
+
+// validateFlags checks the flags against the command definition, and returns
+// them normalized, or an error naming the bad flag. List values are split at commas.
+func validateFlags(command BotCommand, flags map[string][]string) (map[string][]string, error) {
+	definition := command.Definition()
+	if definition == nil || len(definition.Flags) == 0 {
+		return nil, nil
+	}
+	usage := fmt.Sprintf(codeMessageFormat, command.Usage())
+
+	known := map[string]Param{}
+	var names []string
+	for _, flag := range definition.Flags {
+		known[strings.ToLower(flag.Name)] = flag
+		names = append(names, fmt.Sprintf(codeMessageFormat, flagPrefix+flag.Name))
+	}
+	var unknown []string
+	for key := range flags {
+		if _, ok := known[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf(unknownFlagFormat, flagPrefix+unknown[0], strings.Join(names, ", "), usage)
+	}
+
+	normalized := map[string][]string{}
+	for key, flag := range known {
+		values := flags[key]
+		if len(values) == 0 && flag.Required {
+			return nil, fmt.Errorf(missingParamFormat, flagPrefix+flag.Name, flag.expected(), usage)
+		}
+		for _, value := range values {
+			items := []string{value}
+			if flag.Type == ParamList {
+				items = listSeparators.Split(strings.Trim(value, ", "), -1)
+			}
+			for _, item := range items {
+				n, ok := flag.normalize(item)
+				if !ok {
+					return nil, fmt.Errorf(invalidParamFormat, flagPrefix+flag.Name, item, flag.expected(), usage)
+				}
+				normalized[flag.Name] = append(normalized[flag.Name], n)
+			}
+		}
+	}
+	return normalized, nil
+}
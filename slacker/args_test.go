@@ -0,0 +1,92 @@
+package slacker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"  bz   stats b ", []string{"bz", "stats", "b"}},
+		{`say "hello  world" again`, []string{"say", "hello  world", "again"}},
+		{`say 'single quoted'`, []string{"say", "single quoted"}},
+		{"say don't stop", []string{"say", "don't", "stop"}},
+		{`component="kube apiserver"`, []string{"component=kube apiserver"}},
+		{`say "unterminated quote`, []string{"say", "unterminated quote"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, token := range tokenize(tt.in) {
+			got = append(got, token.text)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMatchCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		usage      string
+		definition *CommandDefinition
+		text       string
+		match      bool
+		parameters map[string]string
+		extra      []string
+	}{
+		{name: "exact", usage: "version", text: "version", match: true},
+		{name: "case-insensitive", usage: "bz stats <group>", text: "BZ Stats b", match: true, parameters: map[string]string{"group": "b"}},
+		{name: "not at the start", usage: "version", text: "what version"},
+		{name: "missing parameter", usage: "bz stats <group>", text: "bz stats", match: true},
+		{name: "free text", usage: "say <message>", text: "say hello   world", match: true, parameters: map[string]string{"message": "hello   world"}},
+		{name: "quoted free text", usage: "say <message>", text: `say "hello" world`, match: true, parameters: map[string]string{"message": "hello world"}},
+		{name: "quoted parameter", usage: "cancel <job>", definition: &CommandDefinition{Params: []Param{{Name: "job", Type: ParamInt}}}, text: `cancel "3"`, match: true, parameters: map[string]string{"job": "3"}},
+		{name: "extra argument", usage: "version", text: "version now", match: true, extra: []string{"now"}},
+		{name: "extra argument after typed parameter", usage: "cancel <job>", definition: &CommandDefinition{Params: []Param{{Name: "job", Type: ParamInt}}}, text: "cancel 3 4", match: true, parameters: map[string]string{"job": "3"}, extra: []string{"4"}},
+		{name: "alias", usage: "bz stats <group>", definition: &CommandDefinition{Aliases: []string{"bz-stats"}}, text: "bz-stats c", match: true, parameters: map[string]string{"group": "c"}},
+		{name: "flags", usage: "bz query", definition: &CommandDefinition{Flags: []Param{{Name: "status"}}}, text: `bz query --status NEW status="ON QA"`, match: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := matchCommand(NewBotCommand(tt.usage, tt.definition), tt.text)
+			if (m != nil) != tt.match {
+				t.Fatalf("matchCommand(%q, %q) = %v, want match %v", tt.usage, tt.text, m, tt.match)
+			}
+			if m == nil {
+				return
+			}
+			for name, want := range tt.parameters {
+				if got := m.parameters.StringParam(name, empty); got != want {
+					t.Errorf("parameter %s = %q, want %q", name, got, want)
+				}
+			}
+			if !reflect.DeepEqual(m.extra, tt.extra) {
+				t.Errorf("extra = %q, want %q", m.extra, tt.extra)
+			}
+		})
+	}
+}
+
+func TestSplitFlags(t *testing.T) {
+	positional, flags := splitFlags(tokenize(`bz query --status NEW --status=ASSIGNED Component="kube apiserver" --verbose`))
+	var words []string
+	for _, token := range positional {
+		words = append(words, token.text)
+	}
+	if want := []string{"bz", "query"}; !reflect.DeepEqual(words, want) {
+		t.Errorf("positional = %q, want %q", words, want)
+	}
+	want := map[string][]string{
+		"status":    {"NEW", "ASSIGNED"},
+		"component": {"kube apiserver"},
+		"verbose":   {trueValue},
+	}
+	if !reflect.DeepEqual(flags, want) {
+		t.Errorf("flags = %v, want %v", flags, want)
+	}
+}
@@ -0,0 +1,350 @@
+package slacker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shomali11/proper"
+	"k8s.io/klog"
+
+	"github.com/sttts/sttts-bot/store"
+	v1 "github.com/sttts/sttts-bot/store/v1"
+)
+
+const (
+	channelCommandName        = "channel"
+	notEnabledHereFormat      = "`%s` is not enabled in this channel. Try it in %s."
+	notEnabledAnywhereFormat  = "`%s` is not enabled in this channel."
+	channelNotAllowlistFormat = "I am not enabled in this channel. Talk to me in %s."
+	channelMentionFormat      = "<#%s>"
+)
+
+// channelConfig holds which commands are enabled in which channels, and their
+// default parameters per channel. Channels without configuration allow all
+// commands, unless the allowlist mode is on.
+type channelConfig struct {
+	allowlist bool
+
+	lock     sync.RWMutex
+	channels map[string]v1.ChannelConfig
+
+	// persistLock orders the writes of the configuration to the store
+	persistLock sync.Mutex
+
+	store store.Store
+}
+
+func newChannelConfig(allowlist bool) *channelConfig {
+	return &channelConfig{
+		allowlist: allowlist,
+		channels:  map[string]v1.ChannelConfig{},
+	}
+}
+
+// persistTo loads the channel configuration from the store and records changes there.
+func (c *channelConfig) persistTo(st store.Store) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.store = st
+	st.ReadState(func(state *v1.State) {
+		if state == nil {
+			return
+		}
+		for channel, config := range state.Channels {
+			c.channels[channel] = copyChannelConfig(config)
+		}
+	})
+}
+
+// allowed returns whether the command may run in the channel of the message, and
+// otherwise a message pointing to the channels where it is enabled. Direct messages
+// are always allowed.
+func (c *channelConfig) allowed(message *Message, command BotCommand) (bool, string) {
+	channel, name := message.Channel, commandName(command)
+	if message.Direct || name == helpCommand || name == channelCommandName || strings.HasPrefix(name, channelCommandName+space) {
+		return true, empty
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	config, ok := c.channels[channel]
+	switch {
+	case !ok && c.allowlist:
+		return false, fmt.Sprintf(channelNotAllowlistFormat, c.channelList(func(v1.ChannelConfig) bool { return true }))
+	case !ok || len(config.Commands) == 0 || contains(config.Commands, name):
+		return true, empty
+	}
+
+	elsewhere := c.channelList(func(config v1.ChannelConfig) bool { return contains(config.Commands, name) })
+	if len(elsewhere) == 0 {
+		return false, fmt.Sprintf(notEnabledAnywhereFormat, name)
+	}
+	return false, fmt.Sprintf(notEnabledHereFormat, name, elsewhere)
+}
+
+// channelList renders the channels matching the filter as mentions.
+func (c *channelConfig) channelList(filter func(v1.ChannelConfig) bool) string {
+	var mentions []string
+	for channel, config := range c.channels {
+		if filter(config) {
+			mentions = append(mentions, fmt.Sprintf(channelMentionFormat, channel))
+		}
+	}
+	sort.Strings(mentions)
+	return strings.Join(mentions, ", ")
+}
+
+// withDefaults fills in the channel defaults for the command parameters not given by the user.
+func (c *channelConfig) withDefaults(channel string, command BotCommand, parameters *proper.Properties) *proper.Properties {
+	c.lock.RLock()
+	defaults := c.channels[channel].Defaults[commandName(command)]
+	c.lock.RUnlock()
+	if len(defaults) == 0 {
+		return parameters
+	}
+
+	merged := map[string]string{}
+	for _, token := range command.Tokenize() {
+		if !token.IsParameter() {
+			continue
+		}
+		if value := parameters.StringParam(token.Word, empty); len(value) > 0 {
+			merged[token.Word] = value
+		} else if value, ok := defaults[token.Word]; ok {
+			merged[token.Word] = value
+		}
+	}
+	return proper.NewProperties(merged)
+}
+
+// update changes the configuration of one channel and persists it, if a store is set.
+// The change gets a copy, because the maps of the stored configurations are shared
+// with the state of the store, which might marshal them any time.
+func (c *channelConfig) update(channel string, change func(config *v1.ChannelConfig)) error {
+	// serialize the writes, without blocking allowed on the store
+	c.persistLock.Lock()
+	defer c.persistLock.Unlock()
+
+	c.lock.Lock()
+	config := copyChannelConfig(c.channels[channel])
+	change(&config)
+	if len(config.Commands) == 0 && len(config.Defaults) == 0 && !c.allowlist {
+		delete(c.channels, channel)
+	} else {
+		c.channels[channel] = config
+	}
+	channels := map[string]v1.ChannelConfig{}
+	for channel, config := range c.channels {
+		channels[channel] = copyChannelConfig(config)
+	}
+	st := c.store
+	c.lock.Unlock()
+
+	if st == nil {
+		return nil
+	}
+	return st.UpdateState(func(old *v1.State) (*v1.State, error) {
+		state := &v1.State{}
+		if old != nil {
+			*state = *old
+		}
+		state.Channels = channels
+		return state, nil
+	})
+}
+
+// copyChannelConfig returns a deep copy of the configuration.
+func copyChannelConfig(config v1.ChannelConfig) v1.ChannelConfig {
+	copied := v1.ChannelConfig{Commands: append([]string(nil), config.Commands...)}
+	if config.Defaults != nil {
+		copied.Defaults = make(map[string]map[string]string, len(config.Defaults))
+		for command, defaults := range config.Defaults {
+			copied.Defaults[command] = make(map[string]string, len(defaults))
+			for param, value := range defaults {
+				copied.Defaults[command][param] = value
+			}
+		}
+	}
+	return copied
+}
+
+func (c *channelConfig) describe(channel string) string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	config, ok := c.channels[channel]
+	if !ok {
+		if c.allowlist {
+			return "I am not enabled in this channel."
+		}
+		return "All commands are enabled in this channel, without defaults."
+	}
+
+	lines := []string{"All commands are enabled in this channel."}
+	if len(config.Commands) > 0 {
+		lines = []string{fmt.Sprintf("Enabled commands: %s", strings.Join(config.Commands, ", "))}
+	}
+	var commands []string
+	for command := range config.Defaults {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	for _, command := range commands {
+		var params []string
+		for param, value := range config.Defaults[command] {
+			params = append(params, fmt.Sprintf("%s=%s", param, value))
+		}
+		sort.Strings(params)
+		lines = append(lines, fmt.Sprintf("Defaults for `%s`: %s", command, strings.Join(params, " ")))
+	}
+	return strings.Join(lines, newLine)
+}
+
+// channelAllowed replies and returns false if the command is not enabled in the channel of the request.
+func (s *Slacker) channelAllowed(request Request, response ResponseWriter) bool {
+	ok, hint := s.channels.allowed(request.Message(), request.Command())
+	if !ok {
+		response.Reply(hint)
+	}
+	return ok
+}
+
+// findCommand returns the command with the given name or alias.
+func (s *Slacker) findCommand(name string) BotCommand {
+	name = strings.ToLower(strings.Join(strings.Fields(name), space))
+	for _, command := range s.botCommands {
+		if contains(commandNames(command), name) {
+			return command
+		}
+	}
+	return nil
+}
+
+// commandPrefix returns the command whose name or alias is the longest prefix of the words, and the words after it.
+func (s *Slacker) commandPrefix(words []string) (BotCommand, []string) {
+	var best BotCommand
+	n := 0
+	for _, command := range s.botCommands {
+		for _, name := range commandNames(command) {
+			if l := len(strings.Fields(name)); l > n && l <= len(words) && strings.EqualFold(strings.Join(words[:l], space), name) {
+				best, n = command, l
+			}
+		}
+	}
+	return best, words[n:]
+}
+
+// addChannelCommands registers the commands to configure channels from chat.
+func (s *Slacker) addChannelCommands() {
+	reply := func(response ResponseWriter, err error, message string) {
+		if err != nil {
+			klog.Errorf("Failed to update channel configuration: %v", err)
+			response.ReportError(err)
+			return
+		}
+		response.Reply(message)
+	}
+
+	s.Command("channel show", &CommandDefinition{
+		Description: "Show which commands are enabled in this channel, and their defaults.",
+		Category:    "Channels",
+		Handler: func(request Request, response ResponseWriter) {
+			response.Reply(s.channels.describe(request.Message().Channel))
+		},
+	})
+	s.Command("channel enable <command>", &CommandDefinition{
+		Description: "Enable a command in this channel. Once one is enabled, all others are disabled.",
+		Example:     "channel enable bz stats",
+		Category:    "Channels",
+		Roles:       []string{AdminRole},
+		Handler: func(request Request, response ResponseWriter) {
+			command := s.findCommand(request.Param("command"))
+			if command == nil {
+				response.ReportError(fmt.Errorf("unknown command %q", request.Param("command")))
+				return
+			}
+			name := commandName(command)
+			err := s.channels.update(request.Message().Channel, func(config *v1.ChannelConfig) {
+				if !contains(config.Commands, name) {
+					config.Commands = append(config.Commands, name)
+					sort.Strings(config.Commands)
+				}
+			})
+			reply(response, err, fmt.Sprintf("Enabled `%s` in this channel.", name))
+		},
+	})
+	s.Command("channel disable <command>", &CommandDefinition{
+		Description: "Remove a command from the enabled commands of this channel.",
+		Example:     "channel disable bz stats",
+		Category:    "Channels",
+		Roles:       []string{AdminRole},
+		Handler: func(request Request, response ResponseWriter) {
+			name := strings.ToLower(request.Param("command"))
+			if command := s.findCommand(name); command != nil {
+				name = commandName(command)
+			}
+			err := s.channels.update(request.Message().Channel, func(config *v1.ChannelConfig) {
+				var commands []string
+				for _, c := range config.Commands {
+					if c != name {
+						commands = append(commands, c)
+					}
+				}
+				config.Commands = commands
+			})
+			reply(response, err, fmt.Sprintf("Disabled `%s` in this channel.", name))
+		},
+	})
+	s.Command("channel reset", &CommandDefinition{
+		Description: "Enable all commands in this channel and remove all defaults.",
+		Category:    "Channels",
+		Roles:       []string{AdminRole},
+		Handler: func(request Request, response ResponseWriter) {
+			err := s.channels.update(request.Message().Channel, func(config *v1.ChannelConfig) {
+				*config = v1.ChannelConfig{}
+			})
+			reply(response, err, "All commands are enabled in this channel again.")
+		},
+	})
+	s.Command("channel default <command> <param> <value>", &CommandDefinition{
+		Description: "Set the default of a command parameter in this channel. An empty value removes the default.",
+		Example:     "channel default bz stats group c",
+		Category:    "Channels",
+		Roles:       []string{AdminRole},
+		Handler: func(request Request, response ResponseWriter) {
+			// the command name may have several words, so the parameters are split after it
+			words := strings.Fields(strings.Join([]string{request.Param("command"), request.Param("param"), request.Param("value")}, space))
+			command, rest := s.commandPrefix(words)
+			if command == nil {
+				response.ReportError(fmt.Errorf("unknown command %q", request.Param("command")))
+				return
+			}
+			if len(rest) == 0 {
+				response.ReportError(fmt.Errorf("missing parameter of `%s`", commandName(command)))
+				return
+			}
+			name, param, value := commandName(command), rest[0], strings.Join(rest[1:], space)
+			err := s.channels.update(request.Message().Channel, func(config *v1.ChannelConfig) {
+				if config.Defaults == nil {
+					config.Defaults = map[string]map[string]string{}
+				}
+				if config.Defaults[name] == nil {
+					config.Defaults[name] = map[string]string{}
+				}
+				if len(value) == 0 {
+					delete(config.Defaults[name], param)
+				} else {
+					config.Defaults[name][param] = value
+				}
+				if len(config.Defaults[name]) == 0 {
+					delete(config.Defaults, name)
+				}
+			})
+			reply(response, err, fmt.Sprintf("Set default `%s=%s` for `%s` in this channel.", param, value, name))
+		},
+	})
+}
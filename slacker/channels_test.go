@@ -0,0 +1,127 @@
+package slacker
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shomali11/proper"
+
+	v1 "github.com/sttts/sttts-bot/store/v1"
+)
+
+// memoryStore keeps the state in memory, and calls the hook before each update.
+type memoryStore struct {
+	lock   sync.RWMutex
+	state  *v1.State
+	states []*v1.State
+	hook   func()
+}
+
+func (s *memoryStore) UpdateState(tx func(old *v1.State) (*v1.State, error)) error {
+	if s.hook != nil {
+		s.hook()
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	state, err := tx(s.state)
+	if err != nil {
+		return err
+	}
+	s.state = state
+	s.states = append(s.states, state)
+	return nil
+}
+
+func (s *memoryStore) ReadState(process func(*v1.State)) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	process(s.state)
+}
+
+func TestChannelConfigUpdate(t *testing.T) {
+	command := NewBotCommand("bz stats <group>", nil)
+	c := newChannelConfig(false)
+	st := &memoryStore{}
+	c.persistTo(st)
+
+	setDefault := func(value string) {
+		err := c.update("C1", func(config *v1.ChannelConfig) {
+			if config.Defaults == nil {
+				config.Defaults = map[string]map[string]string{}
+			}
+			if config.Defaults["bz stats"] == nil {
+				config.Defaults["bz stats"] = map[string]string{}
+			}
+			config.Defaults["bz stats"]["group"] = value
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// other channels are served while the store is written
+	st.hook = func() {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			c.allowed(&Message{Channel: "C2"}, command)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Errorf("allowed blocked while the store was written")
+		}
+	}
+	setDefault("c")
+	setDefault("d")
+
+	if len(st.states) != 2 {
+		t.Fatalf("got %d state updates, want 2", len(st.states))
+	}
+	// the state written first must not change with the second update
+	if got := st.states[0].Channels["C1"].Defaults["bz stats"]["group"]; got != "c" {
+		t.Errorf("the first persisted default changed to %q", got)
+	}
+	if got := st.states[1].Channels["C1"].Defaults["bz stats"]["group"]; got != "d" {
+		t.Errorf("persisted default %q, want d", got)
+	}
+	if got := c.withDefaults("C1", command, proper.NewProperties(map[string]string{})).StringParam("group", empty); got != "d" {
+		t.Errorf("default %q, want d", got)
+	}
+
+	// the loaded state is not shared either
+	loaded := newChannelConfig(false)
+	loaded.persistTo(st)
+	loaded.update("C1", func(config *v1.ChannelConfig) { config.Defaults["bz stats"]["group"] = "e" })
+	if got := st.states[1].Channels["C1"].Defaults["bz stats"]["group"]; got != "d" {
+		t.Errorf("the loaded default changed the stored one to %q", got)
+	}
+}
+
+func TestChannelAllowed(t *testing.T) {
+	stats := NewBotCommand("bz stats <group>", nil)
+	c := newChannelConfig(true)
+	c.update("CBZ", func(config *v1.ChannelConfig) { config.Commands = []string{"bz stats"} })
+	c.update("COTHER", func(config *v1.ChannelConfig) { config.Commands = []string{"version"} })
+
+	tests := []struct {
+		name    string
+		channel string
+		direct  bool
+		want    bool
+	}{
+		{"enabled", "CBZ", false, true},
+		{"not enabled", "COTHER", false, false},
+		{"not allowlisted", "CNEW", false, false},
+		{"direct message", "DNEW", true, true},
+		// Mattermost channel IDs do not tell whether they are direct
+		{"Mattermost direct message", "4xp9fdt77pncbef59f4k1qe83o", true, true},
+	}
+	for _, tt := range tests {
+		if got, _ := c.allowed(&Message{Channel: tt.channel, Direct: tt.direct}, stats); got != tt.want {
+			t.Errorf("%s: allowed in %s = %v, want %v", tt.name, tt.channel, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package slacker
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/slack-go/slack"
+	"k8s.io/klog"
+)
+
+const (
+	retryAfterHeader = "Retry-After"
+
+	// defaultRetryAfter is waited if Slack sends no Retry-After header with a 429
+	defaultRetryAfter = time.Second
+)
+
+// newHTTPClient returns the HTTP client for the Slack API, with proxy, timeouts
+// and retries on rate limits as configured.
+func newHTTPClient(opt Options) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc(opt)
+	if opt.HTTPTimeout > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: opt.HTTPTimeout, KeepAlive: 30 * time.Second}).DialContext
+		transport.TLSHandshakeTimeout = opt.HTTPTimeout
+		transport.ResponseHeaderTimeout = opt.HTTPTimeout
+	}
+
+	return &http.Client{Transport: &retryTransport{
+		next:       transport,
+		maxRetries: opt.RateLimitRetries,
+		maxWait:    opt.RateLimitMaxWait,
+	}}
+}
+
+// newWebsocketDialer returns the dialer for Socket Mode connections, with proxy and timeout as configured.
+func newWebsocketDialer(opt Options) *websocket.Dialer {
+	dialer := *websocket.DefaultDialer
+	dialer.Proxy = proxyFunc(opt)
+	if opt.HTTPTimeout > 0 {
+		dialer.HandshakeTimeout = opt.HTTPTimeout
+	}
+	return &dialer
+}
+
+// proxyFunc returns the configured proxy, or the one from the environment.
+func proxyFunc(opt Options) func(*http.Request) (*url.URL, error) {
+	if len(opt.Proxy) == 0 {
+		return http.ProxyFromEnvironment
+	}
+	// validated in ValidateOptions
+	proxy, _ := url.Parse(opt.Proxy)
+	return http.ProxyURL(proxy)
+}
+
+// retryTransport repeats requests Slack answers with 429 Too Many Requests,
+// after the time given in the Retry-After header.
+type retryTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+	maxWait    time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= t.maxRetries {
+			return resp, err
+		}
+		// streamed bodies like file uploads cannot be sent again
+		if req.Body != nil && req.GetBody == nil {
+			return resp, err
+		}
+
+		wait := retryAfter(resp.Header)
+		if wait > t.maxWait {
+			return resp, err
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		metrics.Add(metricSlackAPIRateLimited, 1)
+		klog.Warningf("Slack API %s rate limited, retrying in %v", req.URL.Path, wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryAfter returns the wait time of a 429 response.
+func retryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get(retryAfterHeader))
+	if err != nil || seconds < 0 {
+		return defaultRetryAfter
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// newClient creates the Slack client according to the client defaults.
+func (s *Slacker) newClient() *slack.Client {
+	return slack.New(s.token,
+		slack.OptionDebug(s.clientDefaults.Debug),
+		slack.OptionAPIURL(s.clientDefaults.APIURL),
+		slack.OptionHTTPClient(s.clientDefaults.HTTPClient),
+	)
+}
@@ -1,6 +1,9 @@
 package slacker
 
 import (
+	"strings"
+	"time"
+
 	"github.com/shomali11/commander"
 	"github.com/shomali11/proper"
 )
@@ -9,18 +12,52 @@ import (
 type CommandDefinition struct {
 	Description       string
 	Example           string
+	Category          string
+	Aliases           []string
+	Params            []Param
 	AuthorizationFunc func(request Request) bool
 	Handler           func(request Request, response ResponseWriter)
+
+	// RootAliases are aliases of commands in a group which are not relative to the group,
+	// e.g. to keep the name a command had before it moved into the group
+	RootAliases []string
+	// Flags are named arguments given anywhere after the command as "--name value",
+	// "--name=value" or "name=value", possibly repeated, see Request.Values
+	Flags []Param
+	// MaxConcurrency limits how many invocations of the command run at the same time. Zero means unlimited
+	MaxConcurrency int
+	// RateLimit limits how often each user may invoke the command. Nil means unlimited
+	RateLimit *RateLimit
+	// Roles the user needs one of to run the command, see RBACConfig. Empty means everybody.
+	// Without RBAC, commands with roles cannot be run by anybody
+	Roles []string
+	// ThreadReplies makes replies and errors go into a thread of the triggering message by default, see WithThreadReply
+	ThreadReplies bool
+	// Timeout cancels the context of the request after this duration. Zero means the default of --command-timeout, negative means none
+	Timeout time.Duration
+}
+
+// aliases returns the Aliases followed by the RootAliases.
+func (d *CommandDefinition) aliases() []string {
+	return append(append([]string(nil), d.Aliases...), d.RootAliases...)
 }
 
-// NewBotCommand creates a new bot command object
+// NewBotCommand creates a new bot command object. Aliases replace the leading
+// words of the usage, i.e. the alias "echo" of "say <message>" matches "echo <message>"
 func NewBotCommand(usage string, definition *CommandDefinition) BotCommand {
 	command := commander.NewCommand(usage)
-	return &botCommand{
+	c := &botCommand{
 		usage:      usage,
 		definition: definition,
 		command:    command,
 	}
+	if definition != nil {
+		params := strings.Fields(usage)[len(strings.Fields(leadingWords(command.Tokenize()))):]
+		for _, alias := range definition.aliases() {
+			c.aliases = append(c.aliases, commander.NewCommand(strings.Join(append(strings.Fields(alias), params...), " ")))
+		}
+	}
+	return c
 }
 
 // botCommand structure contains the bot's command, description and handler
@@ -28,6 +65,8 @@ type botCommand struct {
 	usage      string
 	definition *CommandDefinition
 	command    *commander.Command
+	aliases    []*commander.Command
+	group      *CommandGroup
 }
 
 // BotCommand interface
@@ -50,9 +89,25 @@ func (c *botCommand) Definition() *CommandDefinition {
 	return c.definition
 }
 
-// Match determines whether the bot should respond based on the text received
+// Match determines whether the bot should respond based on the text received, case-insensitively and including aliases.
+// The text must start with the command and must not have more words than it takes
 func (c *botCommand) Match(text string) (*proper.Properties, bool) {
-	return c.command.Match(text)
+	m := matchCommand(c, text)
+	if m == nil || len(m.extra) > 0 {
+		return nil, false
+	}
+	return m.parameters, true
+}
+
+// match matches the tokens against the usage and the aliases, preferring the one with the most words.
+func (c *botCommand) match(tokens []argToken, text string) *commandMatch {
+	var best *commandMatch
+	for _, command := range append([]*commander.Command{c.command}, c.aliases...) {
+		if m := matchUsage(command.Tokenize(), tokens, text, c.definition); m != nil && (best == nil || m.words > best.words) {
+			best = m
+		}
+	}
+	return best
 }
 
 // Tokenize returns the command format's tokens
@@ -67,3 +122,15 @@ func (c *botCommand) Execute(request Request, response ResponseWriter) {
 	}
 	c.definition.Handler(request, response)
 }
+
+// leadingWords returns the leading words of a command, without parameters
+func leadingWords(tokens []*commander.Token) string {
+	var words []string
+	for _, token := range tokens {
+		if token.IsParameter() {
+			break
+		}
+		words = append(words, token.Word)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,145 @@
+package slacker
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	"github.com/sttts/sttts-bot/store"
+	v1 "github.com/sttts/sttts-bot/store/v1"
+)
+
+// eventDeduplicator remembers recently seen event IDs, bounded in size and by
+// age. Slack retries events which were not acked in time, with the same event ID.
+type eventDeduplicator struct {
+	ttl     time.Duration
+	maxSize int
+	now     func() time.Time
+
+	lock  sync.Mutex
+	seen  map[string]*list.Element
+	order *list.List // of *seenEvent, oldest first
+	dirty bool       // seen changed since the last flush
+
+	store store.Store
+}
+
+type seenEvent struct {
+	id   string
+	when time.Time
+}
+
+func newEventDeduplicator(ttl time.Duration, maxSize int) *eventDeduplicator {
+	return &eventDeduplicator{
+		ttl:     ttl,
+		maxSize: maxSize,
+		now:     time.Now,
+		seen:    map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// persistTo loads previously seen event IDs from the store and records new ones there.
+func (d *eventDeduplicator) persistTo(st store.Store) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.store = st
+	st.ReadState(func(state *v1.State) {
+		if state == nil || state.SlackEvents == nil {
+			return
+		}
+		for id, when := range state.SlackEvents.Seen {
+			d.add(id, when.Time)
+		}
+	})
+	d.expire()
+}
+
+// Seen returns true if the event ID was seen before within the TTL, and records it otherwise.
+func (d *eventDeduplicator) Seen(id string) bool {
+	if len(id) == 0 {
+		return false
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.expire()
+	if _, ok := d.seen[id]; ok {
+		return true
+	}
+
+	d.add(id, d.now())
+	d.dirty = true
+	return false
+}
+
+func (d *eventDeduplicator) add(id string, when time.Time) {
+	if _, ok := d.seen[id]; ok {
+		return
+	}
+
+	// keep the list sorted by time, loaded entries might come in random order
+	e := d.order.Back()
+	for e != nil && e.Value.(*seenEvent).when.After(when) {
+		e = e.Prev()
+	}
+	if e == nil {
+		d.seen[id] = d.order.PushFront(&seenEvent{id: id, when: when})
+	} else {
+		d.seen[id] = d.order.InsertAfter(&seenEvent{id: id, when: when}, e)
+	}
+
+	for d.order.Len() > d.maxSize {
+		d.remove(d.order.Front())
+	}
+}
+
+func (d *eventDeduplicator) expire() {
+	deadline := d.now().Add(-d.ttl)
+	for e := d.order.Front(); e != nil && e.Value.(*seenEvent).when.Before(deadline); e = d.order.Front() {
+		d.remove(e)
+	}
+}
+
+func (d *eventDeduplicator) remove(e *list.Element) {
+	delete(d.seen, e.Value.(*seenEvent).id)
+	d.order.Remove(e)
+}
+
+// flush persists the seen event IDs if they changed since the last flush. The
+// stored IDs are replaced, i.e. they are bounded by TTL and size like in memory.
+func (d *eventDeduplicator) flush() {
+	d.lock.Lock()
+	if d.store == nil || !d.dirty {
+		d.lock.Unlock()
+		return
+	}
+	d.expire()
+	seen := make(map[string]metav1.Time, d.order.Len())
+	for e := d.order.Front(); e != nil; e = e.Next() {
+		event := e.Value.(*seenEvent)
+		seen[event.id] = metav1.NewTime(event.when)
+	}
+	d.dirty = false
+	d.lock.Unlock()
+
+	err := d.store.UpdateState(func(old *v1.State) (*v1.State, error) {
+		state := &v1.State{}
+		if old != nil {
+			*state = *old
+		}
+		state.SlackEvents = &v1.SlackEvents{Seen: seen}
+		return state, nil
+	})
+	if err != nil {
+		klog.Warningf("Failed to persist %d Slack event IDs: %v", len(seen), err)
+		d.lock.Lock()
+		d.dirty = true
+		d.lock.Unlock()
+	}
+}
@@ -0,0 +1,34 @@
+package slacker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventDeduplicatorSeen(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	d := newEventDeduplicator(time.Minute, 2)
+	d.now = func() time.Time { return now }
+
+	if d.Seen("") {
+		t.Errorf("empty event ID was seen")
+	}
+	if d.Seen("Ev1") {
+		t.Errorf("new event Ev1 was seen")
+	}
+	if !d.Seen("Ev1") {
+		t.Errorf("retried event Ev1 was not seen")
+	}
+
+	now = now.Add(time.Second)
+	d.Seen("Ev2")
+	d.Seen("Ev3")
+	if d.Seen("Ev1") {
+		t.Errorf("Ev1 was not evicted beyond the maximal size")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if d.Seen("Ev3") {
+		t.Errorf("Ev3 did not expire after the TTL")
+	}
+}
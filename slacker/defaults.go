@@ -1,6 +1,10 @@
 package slacker
 
-import "github.com/slack-go/slack"
+import (
+	"net/http"
+
+	"github.com/slack-go/slack"
+)
 
 // ClientOption an option for client values
 type ClientOption func(*ClientDefaults)
@@ -12,14 +16,32 @@ func WithDebug(debug bool) ClientOption {
 	}
 }
 
+// WithAPIURL sets the Slack Web API endpoint, ending in a slash
+func WithAPIURL(apiURL string) ClientOption {
+	return func(defaults *ClientDefaults) {
+		defaults.APIURL = apiURL
+	}
+}
+
+// WithHTTPClient sets the HTTP client for the Slack API
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(defaults *ClientDefaults) {
+		defaults.HTTPClient = client
+	}
+}
+
 // ClientDefaults configuration
 type ClientDefaults struct {
-	Debug bool
+	Debug      bool
+	APIURL     string
+	HTTPClient *http.Client
 }
 
 func newClientDefaults(options ...ClientOption) *ClientDefaults {
 	config := &ClientDefaults{
-		Debug: false,
+		Debug:      false,
+		APIURL:     slack.APIURL,
+		HTTPClient: http.DefaultClient,
 	}
 
 	for _, option := range options {
@@ -52,11 +74,27 @@ func WithThreadReply(useThread bool) ReplyOption {
 	}
 }
 
+// WithBroadcast specifies a thread reply to be shown in the channel as well
+func WithBroadcast(broadcast bool) ReplyOption {
+	return func(defaults *ReplyDefaults) {
+		defaults.Broadcast = broadcast
+	}
+}
+
+// WithInChannel specifies a slash command reply to be visible to the whole channel instead of ephemeral
+func WithInChannel(inChannel bool) ReplyOption {
+	return func(defaults *ReplyDefaults) {
+		defaults.InChannel = inChannel
+	}
+}
+
 // ReplyDefaults configuration
 type ReplyDefaults struct {
 	Attachments    []slack.Attachment
 	Blocks         []slack.Block
 	ThreadResponse bool
+	Broadcast      bool
+	InChannel      bool
 }
 
 func newReplyDefaults(options ...ReplyOption) *ReplyDefaults {
@@ -64,6 +102,8 @@ func newReplyDefaults(options ...ReplyOption) *ReplyDefaults {
 		Attachments:    []slack.Attachment{},
 		Blocks:         []slack.Block{},
 		ThreadResponse: false,
+		Broadcast:      false,
+		InChannel:      false,
 	}
 
 	for _, option := range options {
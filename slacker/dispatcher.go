@@ -0,0 +1,205 @@
+package slacker
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"k8s.io/klog"
+)
+
+const busyMessage = "I'm busy, try again shortly."
+
+// dispatcher runs work on a fixed number of workers fed by a bounded queue,
+// and limits the concurrency of single commands.
+type dispatcher struct {
+	workers int
+	queue   chan func()
+	done    sync.WaitGroup
+
+	lock   sync.Mutex
+	limits map[string]chan struct{}
+}
+
+func newDispatcher(workers, queueSize int) *dispatcher {
+	return &dispatcher{
+		workers: workers,
+		queue:   make(chan func(), queueSize),
+		limits:  map[string]chan struct{}{},
+	}
+}
+
+// start runs the workers. The returned func stops them, after the work they are running.
+func (d *dispatcher) start() func() {
+	stop := make(chan struct{})
+	for i := 0; i < d.workers; i++ {
+		d.done.Add(1)
+		go d.work(stop)
+	}
+	return func() {
+		close(stop)
+		d.done.Wait()
+	}
+}
+
+func (d *dispatcher) work(stop <-chan struct{}) {
+	defer d.done.Done()
+	for {
+		select {
+		case <-stop:
+			return
+		case work := <-d.queue:
+			work()
+		}
+	}
+}
+
+// trySubmit queues the work, or returns false if the queue is full.
+func (d *dispatcher) trySubmit(work func()) bool {
+	select {
+	case d.queue <- work:
+		return true
+	default:
+		return false
+	}
+}
+
+// limit restricts the given command to at most n concurrent executions.
+func (d *dispatcher) limit(usage string, n int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.limits[usage] = make(chan struct{}, n)
+}
+
+// acquire takes a slot for the command. It returns false if the command runs
+// too often already, otherwise the returned func must be called when done.
+func (d *dispatcher) acquire(usage string) (func(), bool) {
+	d.lock.Lock()
+	slots, ok := d.limits[usage]
+	d.lock.Unlock()
+	if !ok {
+		return func() {}, true
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	default:
+		return nil, false
+	}
+}
+
+// submit queues the work, or replies that the bot is busy if the queue is full.
+// The work and the busy reply are tracked for draining on shutdown. If the drain
+// deadline cancels the work while it is still queued, or while it runs before it
+// replied, the user is told that the bot is restarting. The work must reply through
+// the recorder passed to it.
+func (s *Slacker) submit(response ResponseWriter, work func(ctx context.Context, recorder *replyRecorder)) {
+	s.running.Add(1)
+	tracked := func() {
+		defer s.running.Done()
+
+		ctx := s.handlerCtx
+		recorder := &replyRecorder{ResponseWriter: response}
+		if ctx.Err() == nil {
+			work(ctx, recorder)
+		}
+		if ctx.Err() != nil && !recorder.hasReplied() {
+			if _, err := response.Reply(restartingMessage); err != nil {
+				klog.Error(err)
+			}
+		}
+	}
+	if s.dispatcher.trySubmit(tracked) {
+		return
+	}
+
+	klog.Warningf("Dispatch queue full, rejecting work")
+	metrics.Add(metricDispatchQueueFull, 1)
+	go func() {
+		defer s.running.Done()
+		if _, err := response.Reply(busyMessage); err != nil {
+			klog.Error(err)
+		}
+	}()
+}
+
+// submitCommand submits the work handling the command text, except for the commands
+// managing jobs. These are cheap and run right away on the receiving goroutine, so
+// that cancel does not wait for a free worker, which might be busy with the job to cancel.
+func (s *Slacker) submitCommand(text string, response ResponseWriter, work func(ctx context.Context, recorder *replyRecorder)) {
+	if !s.isJobCommand(text) {
+		s.submit(response, work)
+		return
+	}
+	s.running.Add(1)
+	defer s.running.Done()
+	work(s.handlerCtx, &replyRecorder{ResponseWriter: response})
+}
+
+// replyRecorder records whether a handler replied to the user, not counting
+// reactions and progress updates before the final one.
+type replyRecorder struct {
+	ResponseWriter
+	replied int32
+}
+
+func (r *replyRecorder) unwrap() ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *replyRecorder) hasReplied() bool {
+	return atomic.LoadInt32(&r.replied) != 0
+}
+
+func (r *replyRecorder) record() {
+	atomic.StoreInt32(&r.replied, 1)
+}
+
+func (r *replyRecorder) Reply(text string, options ...ReplyOption) (string, error) {
+	r.record()
+	return r.ResponseWriter.Reply(text, options...)
+}
+
+func (r *replyRecorder) ReplyEphemeral(text string, options ...ReplyOption) (string, error) {
+	r.record()
+	return r.ResponseWriter.ReplyEphemeral(text, options...)
+}
+
+func (r *replyRecorder) UploadFile(name string, content io.Reader) (string, error) {
+	r.record()
+	return r.ResponseWriter.UploadFile(name, content)
+}
+
+func (r *replyRecorder) ReportError(err error, options ...ReportErrorOption) {
+	r.record()
+	r.ResponseWriter.ReportError(err, options...)
+}
+
+func (r *replyRecorder) Progress() Progress {
+	return &progressRecorder{Progress: r.ResponseWriter.Progress(), recorder: r}
+}
+
+// progressRecorder records the final status of a progress as reply.
+type progressRecorder struct {
+	Progress
+	recorder *replyRecorder
+}
+
+func (p *progressRecorder) Done(text string, options ...ReplyOption) error {
+	p.recorder.record()
+	return p.Progress.Done(text, options...)
+}
+
+func (p *progressRecorder) Fail(err error) error {
+	p.recorder.record()
+	return p.Progress.Fail(err)
+}
+
+// setThreadReplies passes the default on, if the recorded ResponseWriter supports it.
+func (r *replyRecorder) setThreadReplies(threadReplies bool) {
+	if t, ok := r.ResponseWriter.(threadReplier); ok {
+		t.setThreadReplies(threadReplies)
+	}
+}
@@ -0,0 +1,149 @@
+package slacker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingResponse records the messages posted through it.
+type recordingResponse struct {
+	lock     sync.Mutex
+	messages []string
+}
+
+func (r *recordingResponse) post(text string) (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.messages = append(r.messages, text)
+	return fmt.Sprintf("%d", len(r.messages)), nil
+}
+
+func (r *recordingResponse) Reply(text string, options ...ReplyOption) (string, error) {
+	return r.post(text)
+}
+
+func (r *recordingResponse) ReplyEphemeral(text string, options ...ReplyOption) (string, error) {
+	return r.post(text)
+}
+
+func (r *recordingResponse) Update(timestamp string, text string, options ...ReplyOption) (string, error) {
+	return r.post(text)
+}
+
+func (r *recordingResponse) Delete(timestamp string) error { return nil }
+
+func (r *recordingResponse) React(emoji string) error { return errNoMessage }
+
+func (r *recordingResponse) UploadFile(name string, content io.Reader) (string, error) {
+	return r.post(name)
+}
+
+func (r *recordingResponse) DM(user string, text string, options ...ReplyOption) (string, error) {
+	return r.post(text)
+}
+
+func (r *recordingResponse) Progress() Progress {
+	return newProgress(r)
+}
+
+func (r *recordingResponse) ReportError(err error, options ...ReportErrorOption) {
+	r.post(err.Error())
+}
+
+func (r *recordingResponse) Messages() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.messages...)
+}
+
+func TestDispatcher(t *testing.T) {
+	d := newDispatcher(1, 1)
+	if !d.trySubmit(func() {}) {
+		t.Fatal("the first work was not queued")
+	}
+	if d.trySubmit(func() {}) {
+		t.Error("work was queued beyond the queue size")
+	}
+
+	d.limit("bz stats", 1)
+	release, ok := d.acquire("bz stats")
+	if !ok {
+		t.Fatal("the first execution was rejected")
+	}
+	if _, ok := d.acquire("bz stats"); ok {
+		t.Error("a second concurrent execution was allowed")
+	}
+	if _, ok := d.acquire("version"); !ok {
+		t.Error("an unlimited command was rejected")
+	}
+	release()
+	if _, ok := d.acquire("bz stats"); !ok {
+		t.Error("the released slot was not reusable")
+	}
+}
+
+func TestSubmitQueueFull(t *testing.T) {
+	s := NewSlacker(Options{Workers: 1, QueueSize: 1})
+
+	// no workers are running, the second work does not fit into the queue
+	first, second := &recordingResponse{}, &recordingResponse{}
+	s.submit(first, func(ctx context.Context, recorder *replyRecorder) { recorder.Reply("done") })
+	s.submit(second, func(ctx context.Context, recorder *replyRecorder) { recorder.Reply("done") })
+
+	stop := s.dispatcher.start()
+	s.running.Wait()
+	stop()
+
+	if got := first.Messages(); len(got) != 1 || got[0] != "done" {
+		t.Errorf("the queued work replied %q", got)
+	}
+	if got := second.Messages(); len(got) != 1 || got[0] != busyMessage {
+		t.Errorf("the rejected work replied %q, want the busy message", got)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	s := NewSlacker(Options{Workers: 2, QueueSize: 10, DrainTimeout: 50 * time.Millisecond})
+	stop := s.dispatcher.start()
+	defer stop()
+
+	started := make(chan struct{})
+	finished, replied, interrupted, queued := &recordingResponse{}, &recordingResponse{}, &recordingResponse{}, &recordingResponse{}
+	s.submit(finished, func(ctx context.Context, recorder *replyRecorder) {
+		recorder.Reply("done")
+	})
+	s.submit(replied, func(ctx context.Context, recorder *replyRecorder) {
+		recorder.Reply("working")
+		<-ctx.Done()
+	})
+	s.submit(interrupted, func(ctx context.Context, recorder *replyRecorder) {
+		close(started)
+		<-ctx.Done()
+	})
+	s.submit(queued, func(ctx context.Context, recorder *replyRecorder) {
+		recorder.Reply("should not run")
+	})
+	<-started
+
+	s.drain()
+
+	tests := []struct {
+		name     string
+		response *recordingResponse
+		want     []string
+	}{
+		{"finished", finished, []string{"done"}},
+		{"replied before cancellation", replied, []string{"working"}},
+		{"interrupted", interrupted, []string{restartingMessage}},
+		{"queued", queued, []string{restartingMessage}},
+	}
+	for _, tt := range tests {
+		if got := tt.response.Messages(); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: replied %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
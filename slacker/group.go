@@ -0,0 +1,207 @@
+package slacker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+const emptyGroupFormat = "There is no command in `%s` you can run."
+
+// GroupDefinition describes a command group for the help and for authorization
+type GroupDefinition struct {
+	Description string
+	// Category of the commands of the group which do not set their own
+	Category string
+	// Roles the user needs one of to run any command of the group, in addition to the roles of the command itself
+	Roles []string
+	// AuthorizationFunc must allow the request for any command of the group, in addition to the one of the command itself
+	AuthorizationFunc func(request Request) bool
+}
+
+// CommandGroup is a sub-router registering commands under a common prefix,
+// e.g. the command "show <id>" of the group "bz" is run as "bz show <id>".
+type CommandGroup struct {
+	slacker     *Slacker
+	parent      *CommandGroup
+	path        string
+	definition  GroupDefinition
+	middlewares []Middleware
+}
+
+// Group returns the command group with the given name, creating it on first use
+func (s *Slacker) Group(name string) *CommandGroup {
+	return s.group(nil, name)
+}
+
+func (s *Slacker) group(parent *CommandGroup, name string) *CommandGroup {
+	path := strings.ToLower(strings.Join(strings.Fields(name), space))
+	if parent != nil {
+		path = parent.path + space + path
+	}
+	for _, g := range s.groups {
+		if g.path == path {
+			return g
+		}
+	}
+	g := &CommandGroup{slacker: s, parent: parent, path: path}
+	s.groups = append(s.groups, g)
+	return g
+}
+
+// Name returns the prefix of the commands of the group, including the names of the parent groups
+func (g *CommandGroup) Name() string {
+	return g.path
+}
+
+// Describe sets the description, category and authorization of the group
+func (g *CommandGroup) Describe(definition *GroupDefinition) *CommandGroup {
+	g.definition = *definition
+	return g
+}
+
+// Group returns the nested command group with the given name, creating it on first use
+func (g *CommandGroup) Group(name string) *CommandGroup {
+	return g.slacker.group(g, name)
+}
+
+// Use appends middlewares wrapping the commands of the group and its nested groups.
+// They run inside of the middlewares of the parent group and of the Slacker, after
+// the command was authorized.
+func (g *CommandGroup) Use(middlewares ...Middleware) {
+	g.middlewares = append(g.middlewares, middlewares...)
+}
+
+// Command defines a new command of the group. Its usage and aliases are relative to the group, except the RootAliases.
+func (g *CommandGroup) Command(usage string, definition *CommandDefinition) {
+	d := CommandDefinition{}
+	if definition != nil {
+		d = *definition
+	}
+	if len(d.Category) == 0 {
+		d.Category = g.category()
+	}
+	if handler := d.Handler; handler != nil {
+		d.Handler = func(request Request, response ResponseWriter) {
+			g.chain(handler)(request, response)
+		}
+	}
+	d.Aliases = append([]string(nil), d.Aliases...)
+	for i := range d.Aliases {
+		d.Aliases[i] = g.path + space + d.Aliases[i]
+	}
+	g.slacker.command(g.path+space+usage, &d, g)
+}
+
+// category returns the category of the group or of the closest parent group with one.
+func (g *CommandGroup) category() string {
+	for ; g != nil; g = g.parent {
+		if len(g.definition.Category) > 0 {
+			return g.definition.Category
+		}
+	}
+	return empty
+}
+
+// chain wraps the handler with the middlewares of the group and its parents, the outermost group first.
+func (g *CommandGroup) chain(handler Handler) Handler {
+	for ; g != nil; g = g.parent {
+		for i := len(g.middlewares) - 1; i >= 0; i-- {
+			handler = g.middlewares[i](handler)
+		}
+	}
+	return handler
+}
+
+// mayRun returns true if the user has one of the roles of the group and of each parent group.
+func (g *CommandGroup) mayRun(s *Slacker, user, channel string) bool {
+	for ; g != nil; g = g.parent {
+		if !s.mayRun(user, channel, &CommandDefinition{Roles: g.definition.Roles}) {
+			return false
+		}
+	}
+	return true
+}
+
+// authorized returns true if the authorization funcs of the group and its parents allow the request.
+func (g *CommandGroup) authorized(request Request) bool {
+	for ; g != nil; g = g.parent {
+		if g.definition.AuthorizationFunc != nil && !g.definition.AuthorizationFunc(request) {
+			return false
+		}
+	}
+	return true
+}
+
+// restricted returns true if the group or one of its parents has roles or an authorization func.
+func (g *CommandGroup) restricted() bool {
+	for ; g != nil; g = g.parent {
+		if g.definition.AuthorizationFunc != nil || len(g.definition.Roles) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// contains returns true if the command belongs to the group or one of its nested groups.
+func (g *CommandGroup) contains(command BotCommand) bool {
+	for cg := commandGroup(command); cg != nil; cg = cg.parent {
+		if cg == g {
+			return true
+		}
+	}
+	return false
+}
+
+// commandGroup returns the group the command was registered in, or nil.
+func commandGroup(command BotCommand) *CommandGroup {
+	if c, ok := command.(*botCommand); ok {
+		return c.group
+	}
+	return nil
+}
+
+// findGroup returns the group with the given name, or nil.
+func (s *Slacker) findGroup(name string) *CommandGroup {
+	name = strings.ToLower(strings.Join(strings.Fields(name), space))
+	for _, g := range s.groups {
+		if g.path == name {
+			return g
+		}
+	}
+	return nil
+}
+
+// leadingGroup returns the most nested group whose name the text starts with, or nil.
+func (s *Slacker) leadingGroup(text string) *CommandGroup {
+	words := strings.Fields(strings.ToLower(text))
+	for n := len(words); n > 0; n-- {
+		if g := s.findGroup(strings.Join(words[:n], space)); g != nil {
+			return g
+		}
+	}
+	return nil
+}
+
+// groupHelp renders the description and the commands of the group the user may run.
+func (s *Slacker) groupHelp(g *CommandGroup, commands []BotCommand) []slack.Block {
+	var members []BotCommand
+	for _, command := range commands {
+		if g.contains(command) {
+			members = append(members, command)
+		}
+	}
+
+	lines := []string{fmt.Sprintf(boldMessageFormat, g.path)}
+	if len(g.definition.Description) > 0 {
+		lines[0] += space + dash + space + g.definition.Description
+	}
+	if len(g.definition.Roles) > 0 {
+		lines = append(lines, "Required role: "+strings.Join(g.definition.Roles, " or "))
+	}
+	if len(members) == 0 {
+		lines = append(lines, fmt.Sprintf(emptyGroupFormat, g.path))
+	}
+	return append(sectionBlocks(lines), s.commandOverview(members)...)
+}
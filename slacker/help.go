@@ -0,0 +1,303 @@
+package slacker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	helpUsage           = "help <command>"
+	helpDescription     = "Show the available commands, or details about one of them."
+	helpExample         = "help bz stats"
+	defaultCategory     = "General"
+	helpHintFormat      = "Type `%s <command>` for details about a command."
+	unknownHelpFormat   = "There is no command `%s` you can run. Type `%s` for the list of commands."
+	categoryTitleFormat = "*%s*"
+
+	// Slack limits, see https://api.slack.com/reference/block-kit/blocks
+	maxSectionTextLength = 3000
+	maxBlocksPerMessage  = 50
+)
+
+// Param describes a command parameter for the help and for validation
+type Param struct {
+	Name        string
+	Description string
+	// Type of the values, validated before the handler runs. Empty means any text
+	Type ParamType
+	// Values are the allowed values of ParamEnum and ParamList parameters
+	Values []string
+	// Required parameters must be given by the user or as channel default
+	Required bool
+}
+
+func (s *Slacker) prependHelpHandle() {
+	if s.helpPrepended {
+		return
+	}
+	s.helpPrepended = true
+
+	if s.helpDefinition == nil {
+		s.helpDefinition = &CommandDefinition{}
+	}
+
+	if s.helpDefinition.Handler == nil {
+		s.helpDefinition.Handler = s.defaultHelp
+	}
+
+	if len(s.helpDefinition.Description) == 0 {
+		s.helpDefinition.Description = helpDescription
+	}
+	if len(s.helpDefinition.Example) == 0 {
+		s.helpDefinition.Example = helpExample
+	}
+	if len(s.helpDefinition.Params) == 0 {
+		s.helpDefinition.Params = []Param{{Name: "command", Description: "Optional command to show details for."}}
+	}
+
+	s.botCommands = append([]BotCommand{NewBotCommand(helpUsage, s.helpDefinition)}, s.botCommands...)
+}
+
+// defaultHelp lists the commands the user may run grouped by category, or
+// shows the detail page of the command given as parameter.
+func (s *Slacker) defaultHelp(request Request, response ResponseWriter) {
+	commands := s.runnableCommands(request.Message())
+
+	if topic := strings.TrimSpace(request.Param("command")); len(topic) > 0 {
+		topic = strings.ToLower(strings.Join(strings.Fields(topic), space))
+		if g := s.findGroup(topic); g != nil {
+			s.replyBlocks(response, g.Name(), s.groupHelp(g, commands))
+			return
+		}
+		for _, command := range commands {
+			if contains(commandNames(command), topic) {
+				s.replyBlocks(response, commandName(command), s.commandDetails(command))
+				return
+			}
+		}
+		response.Reply(fmt.Sprintf(unknownHelpFormat, topic, helpCommand))
+		return
+	}
+
+	s.replyBlocks(response, helpCommand, s.commandOverview(commands))
+}
+
+// runnableCommands returns the commands the user who sent the message may run in its channel.
+func (s *Slacker) runnableCommands(message *Message) []BotCommand {
+	var runnable []BotCommand
+	for _, command := range s.botCommands {
+		if allowed, _ := s.channels.allowed(message, command); allowed && s.mayRunCommand(message.User, message.Channel, command) {
+			runnable = append(runnable, command)
+		}
+	}
+	return runnable
+}
+
+// commandOverview renders one section per category, with one line per command.
+func (s *Slacker) commandOverview(commands []BotCommand) []slack.Block {
+	byCategory := map[string][]BotCommand{}
+	var categories []string
+	for _, command := range commands {
+		category := command.Definition().Category
+		if len(category) == 0 {
+			category = defaultCategory
+		}
+		if _, ok := byCategory[category]; !ok {
+			categories = append(categories, category)
+		}
+		byCategory[category] = append(byCategory[category], command)
+	}
+	sort.SliceStable(categories, func(i, j int) bool {
+		// the default category goes first, the others by name
+		if categories[i] == defaultCategory || categories[j] == defaultCategory {
+			return categories[i] == defaultCategory
+		}
+		return categories[i] < categories[j]
+	})
+
+	var blocks []slack.Block
+	authorizedCommandAvailable := false
+	for _, category := range categories {
+		lines := []string{fmt.Sprintf(categoryTitleFormat, category)}
+		for _, command := range byCategory[category] {
+			line := formatUsage(command)
+			if len(command.Definition().Description) > 0 {
+				line += space + dash + space + fmt.Sprintf(italicMessageFormat, command.Definition().Description)
+			}
+			if command.Definition().AuthorizationFunc != nil || len(command.Definition().Roles) > 0 || commandGroup(command).restricted() {
+				authorizedCommandAvailable = true
+				line += space + fmt.Sprintf(codeMessageFormat, star)
+			}
+			lines = append(lines, line)
+		}
+		blocks = append(blocks, sectionBlocks(lines)...)
+	}
+
+	footer := []string{fmt.Sprintf(helpHintFormat, helpCommand)}
+	if authorizedCommandAvailable {
+		footer = append(footer, fmt.Sprintf(codeMessageFormat, star+space+authorizedUsersOnly))
+	}
+	blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, strings.Join(footer, newLine), false, false)))
+
+	return blocks
+}
+
+// commandDetails renders the detail page of one command.
+func (s *Slacker) commandDetails(command BotCommand) []slack.Block {
+	definition := command.Definition()
+
+	lines := []string{formatUsage(command)}
+	if len(definition.Description) > 0 {
+		lines = append(lines, definition.Description)
+	}
+
+	if len(definition.Params) > 0 {
+		lines = append(lines, empty, fmt.Sprintf(boldMessageFormat, "Parameters"))
+		for _, param := range definition.Params {
+			lines = append(lines, formatParam(param.Name, param))
+		}
+	}
+
+	if len(definition.Flags) > 0 {
+		lines = append(lines, empty, fmt.Sprintf(boldMessageFormat, "Options"))
+		for _, flag := range definition.Flags {
+			lines = append(lines, formatParam(flagPrefix+flag.Name+space+strings.ToUpper(flag.Name), flag))
+		}
+		lines = append(lines, "Options may be repeated, and given as `--name=value` or `name=value` too. Quote values with spaces.")
+	}
+
+	if len(definition.Example) > 0 {
+		lines = append(lines, empty, fmt.Sprintf(quoteMessageFormat, definition.Example))
+	}
+
+	var facts []string
+	if names := commandNames(command); len(names) > 1 {
+		facts = append(facts, "Aliases: "+strings.Join(names[1:], ", "))
+	}
+	if len(definition.Category) > 0 {
+		facts = append(facts, "Category: "+definition.Category)
+	}
+	if len(definition.Roles) > 0 {
+		facts = append(facts, "Required role: "+strings.Join(definition.Roles, " or "))
+	}
+	if g := commandGroup(command); g != nil {
+		facts = append(facts, "Group: "+g.Name())
+		for ; g != nil; g = g.parent {
+			if len(g.definition.Roles) > 0 {
+				facts = append(facts, "Required role of "+g.Name()+": "+strings.Join(g.definition.Roles, " or "))
+			}
+		}
+	}
+	if definition.AuthorizationFunc != nil || commandGroup(command).restricted() {
+		facts = append(facts, authorizedUsersOnly)
+	}
+
+	blocks := sectionBlocks(lines)
+	if len(facts) > 0 {
+		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, strings.Join(facts, " | "), false, false)))
+	}
+	return blocks
+}
+
+// replyBlocks sends the blocks, split into as many messages as Slack needs.
+func (s *Slacker) replyBlocks(response ResponseWriter, text string, blocks []slack.Block) {
+	for len(blocks) > 0 {
+		n := len(blocks)
+		if n > maxBlocksPerMessage {
+			n = maxBlocksPerMessage
+		}
+		if _, err := response.Reply(text, WithBlocks(blocks[:n])); err != nil {
+			response.ReportError(err)
+			return
+		}
+		blocks = blocks[n:]
+	}
+}
+
+// sectionBlocks joins the lines into mrkdwn sections, each within Slack's text limit.
+func sectionBlocks(lines []string) []slack.Block {
+	var blocks []slack.Block
+	text := empty
+	flush := func() {
+		if len(text) > 0 {
+			blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil))
+		}
+		text = empty
+	}
+	for _, line := range lines {
+		if len(line) > maxSectionTextLength {
+			line = truncate(line, maxSectionTextLength-3) + "..."
+		}
+		if len(text)+len(newLine)+len(line) > maxSectionTextLength {
+			flush()
+		}
+		if len(text) > 0 {
+			text += newLine
+		}
+		text += line
+	}
+	flush()
+	return blocks
+}
+
+// truncate cuts the text to at most n bytes, without splitting a UTF-8 character.
+func truncate(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n]
+}
+
+// formatUsage renders the usage with bold words and code parameters.
+func formatUsage(command BotCommand) string {
+	var words []string
+	for _, token := range command.Tokenize() {
+		if token.IsParameter() {
+			words = append(words, fmt.Sprintf(codeMessageFormat, token.Word))
+		} else {
+			words = append(words, fmt.Sprintf(boldMessageFormat, token.Word))
+		}
+	}
+	if command.Definition() != nil && len(command.Definition().Flags) > 0 {
+		words = append(words, fmt.Sprintf(codeMessageFormat, "[options]"))
+	}
+	return strings.Join(words, space)
+}
+
+// formatParam renders one line of the parameter or option list.
+func formatParam(name string, param Param) string {
+	line := fmt.Sprintf(codeMessageFormat, name)
+	if param.Type != ParamString {
+		line += space + "(" + param.expected() + ")"
+	}
+	if param.Required {
+		line += space + "required"
+	}
+	if len(param.Description) > 0 {
+		line += space + dash + space + param.Description
+	}
+	return line
+}
+
+// commandName returns the leading words of the usage, without parameters.
+func commandName(command BotCommand) string {
+	return strings.ToLower(leadingWords(command.Tokenize()))
+}
+
+// commandNames returns the command name followed by its aliases, all lower case.
+func commandNames(command BotCommand) []string {
+	names := []string{commandName(command)}
+	if command.Definition() != nil {
+		for _, alias := range command.Definition().aliases() {
+			names = append(names, strings.ToLower(strings.Join(strings.Fields(alias), space)))
+		}
+	}
+	return names
+}
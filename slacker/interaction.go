@@ -0,0 +1,176 @@
+package slacker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// NewActionRequest creates a new ActionRequest structure. The action is nil for view submissions.
+func NewActionRequest(ctx context.Context, callback *slack.InteractionCallback, action *slack.BlockAction) ActionRequest {
+	return &actionRequest{ctx: ctx, callback: callback, action: action}
+}
+
+// ActionRequest interface that contains the interaction payload received from Slack.
+// Actions are interactions with Block Kit elements, so they only exist in Slack
+type ActionRequest interface {
+	Value() string
+	Context() context.Context
+	Callback() *slack.InteractionCallback
+	Action() *slack.BlockAction
+}
+
+// actionRequest contains the interaction payload received
+type actionRequest struct {
+	ctx      context.Context
+	callback *slack.InteractionCallback
+	action   *slack.BlockAction
+}
+
+// Value returns the value of the clicked button or the selected option. It is empty for view submissions
+func (r *actionRequest) Value() string {
+	if r.action == nil {
+		return empty
+	}
+	if len(r.action.SelectedOption.Value) > 0 {
+		return r.action.SelectedOption.Value
+	}
+	return r.action.Value
+}
+
+// Context returns the current context of the request
+func (r *actionRequest) Context() context.Context {
+	return r.ctx
+}
+
+// Callback returns the full interaction payload
+func (r *actionRequest) Callback() *slack.InteractionCallback {
+	return r.callback
+}
+
+// Action returns the block action which triggered the request, or nil for view submissions
+func (r *actionRequest) Action() *slack.BlockAction {
+	return r.action
+}
+
+// actionMessage returns the interaction as message of the interacting user, with
+// the value of the action as text, for the middlewares.
+func actionMessage(callback *slack.InteractionCallback, action *slack.BlockAction) *Message {
+	message := &Message{
+		Platform: SlackPlatform,
+		ID:       callback.Message.Timestamp,
+		User:     callback.User.ID,
+		UserName: callback.User.Name,
+		Channel:  callback.Channel.ID,
+		Thread:   callback.Message.ThreadTimestamp,
+		Direct:   strings.HasPrefix(callback.Channel.ID, directChannelMarker),
+	}
+	if action != nil {
+		message.Text = (&actionRequest{action: action}).Value()
+	}
+	return message
+}
+
+// An ActionResponseWriter interface is used to respond to an interaction in Slack
+type ActionResponseWriter interface {
+	SlackResponseWriter
+	UpdateOriginal(text string, options ...ReplyOption) error
+}
+
+// actionReplyRecorder records the replies of action handlers, including UpdateOriginal.
+type actionReplyRecorder struct {
+	*replyRecorder
+	original ActionResponseWriter
+}
+
+func (r *actionReplyRecorder) Client() *slack.Client {
+	return r.original.Client()
+}
+
+func (r *actionReplyRecorder) UpdateOriginal(text string, options ...ReplyOption) error {
+	r.record()
+	return r.original.UpdateOriginal(text, options...)
+}
+
+// NewActionResponse creates a new response structure for an interaction
+func NewActionResponse(callback *slack.InteractionCallback, client *slack.Client) ActionResponseWriter {
+	return &actionResponse{
+		messenger: messenger{client: client, channel: callback.Channel.ID, user: callback.User.ID, timestamp: callback.Message.Timestamp, threadTimestamp: callback.Message.ThreadTimestamp},
+		callback:  callback,
+	}
+}
+
+type actionResponse struct {
+	messenger
+	callback *slack.InteractionCallback
+}
+
+// ReportError sends back a formatted error message visible only to the user who interacted
+func (r *actionResponse) ReportError(err error, options ...ReportErrorOption) {
+	text := slack.MsgOptionText(fmt.Sprintf(errorFormat, err.Error()), false)
+	switch {
+	case len(r.callback.ResponseURL) > 0:
+		r.client.SendMessage(r.callback.Channel.ID, slack.MsgOptionResponseURL(r.callback.ResponseURL, slack.ResponseTypeEphemeral), text)
+	case len(r.callback.Channel.ID) > 0:
+		r.client.PostEphemeral(r.callback.Channel.ID, r.callback.User.ID, text)
+	default:
+		r.client.PostMessage(r.callback.User.ID, text)
+	}
+}
+
+// Reply sends a new message. It is ephemeral unless WithInChannel is passed. Without
+// a channel, e.g. for view submissions, the user gets a direct message. Replies
+// through the response_url have no timestamp
+func (r *actionResponse) Reply(message string, options ...ReplyOption) (string, error) {
+	defaults := newReplyDefaults(options...)
+
+	opts := []slack.MsgOption{
+		slack.MsgOptionText(message, false),
+		slack.MsgOptionAttachments(defaults.Attachments...),
+		slack.MsgOptionBlocks(defaults.Blocks...),
+	}
+	if len(r.callback.ResponseURL) > 0 {
+		responseType := slack.ResponseTypeEphemeral
+		if defaults.InChannel {
+			responseType = slack.ResponseTypeInChannel
+		}
+		_, _, _, err := r.client.SendMessage(r.callback.Channel.ID, append(opts, slack.MsgOptionResponseURL(r.callback.ResponseURL, responseType))...)
+		return empty, err
+	}
+
+	channel := r.callback.Channel.ID
+	if len(channel) == 0 {
+		channel = r.callback.User.ID
+	}
+	_, timestamp, err := r.client.PostMessage(channel, opts...)
+	return timestamp, err
+}
+
+// UpdateOriginal replaces the message which contained the clicked element
+func (r *actionResponse) UpdateOriginal(message string, options ...ReplyOption) error {
+	defaults := newReplyDefaults(options...)
+
+	opts := []slack.MsgOption{
+		slack.MsgOptionText(message, false),
+		slack.MsgOptionAttachments(defaults.Attachments...),
+		slack.MsgOptionBlocks(defaults.Blocks...),
+	}
+	switch {
+	case len(r.callback.ResponseURL) > 0:
+		_, _, _, err := r.client.SendMessage(r.callback.Channel.ID, append(opts, slack.MsgOptionReplaceOriginal(r.callback.ResponseURL))...)
+		return err
+	case len(r.callback.Channel.ID) > 0 && len(r.callback.Message.Timestamp) > 0:
+		_, _, _, err := r.client.UpdateMessage(r.callback.Channel.ID, r.callback.Message.Timestamp, opts...)
+		return err
+	default:
+		return errors.New("there is no message to update")
+	}
+}
+
+// Progress returns a status message handle for long-running actions
+func (r *actionResponse) Progress() Progress {
+	return newProgress(r)
+}
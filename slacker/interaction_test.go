@@ -0,0 +1,128 @@
+package slacker_test
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sttts/sttts-bot/slacker"
+	"github.com/sttts/sttts-bot/slackertest"
+)
+
+func TestInteractions(t *testing.T) {
+	api := slackertest.NewServer()
+	defer api.Close()
+	handler := newTestBot(t, api, func(bot *slacker.Slacker) {
+		bot.Action("approve", func(request slacker.ActionRequest, response slacker.ActionResponseWriter) {
+			response.UpdateOriginal("approved " + request.Value())
+		})
+		bot.Action("feedback", func(request slacker.ActionRequest, response slacker.ActionResponseWriter) {
+			response.Reply("thanks " + request.Callback().User.ID)
+		})
+	})
+
+	// actions without a handler are ignored
+	for _, actionID := range []string{"unknown", "approve"} {
+		blockAction, _ := json.Marshal(map[string]interface{}{
+			"type":         "block_actions",
+			"user":         map[string]interface{}{"id": testUser},
+			"channel":      map[string]interface{}{"id": testChannel},
+			"message":      map[string]interface{}{"ts": "1500000000.000001"},
+			"response_url": api.ResponseURL(),
+			"actions":      []map[string]interface{}{{"action_id": actionID, "block_id": "decision", "value": "42"}},
+		})
+		if err := slackertest.InjectInteraction(handler, blockAction); err != nil {
+			t.Fatal(err)
+		}
+	}
+	calls, err := api.WaitForCalls(slackertest.ResponseURLMethod, 1, waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := calls[0].Params.Get("text"), "approved 42"; got != want {
+		t.Errorf("updated the original message to %q, want %q", got, want)
+	}
+	if got := calls[0].Params.Get("replace_original"); got != "true" {
+		t.Errorf("replace_original is %q, want true", got)
+	}
+
+	// view submissions have no channel, the reply goes to the user
+	viewSubmission, _ := json.Marshal(map[string]interface{}{
+		"type": "view_submission",
+		"user": map[string]interface{}{"id": testUser},
+		"view": map[string]interface{}{"callback_id": "feedback"},
+	})
+	if err := slackertest.InjectInteraction(handler, viewSubmission); err != nil {
+		t.Fatal(err)
+	}
+	calls, err = api.WaitForCalls("chat.postMessage", 1, waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := calls[0].Params.Get("channel"), testUser; got != want {
+		t.Errorf("replied to %q, want %q", got, want)
+	}
+	if got, want := calls[0].Params.Get("text"), "thanks "+testUser; got != want {
+		t.Errorf("replied %q, want %q", got, want)
+	}
+	if calls := api.CallsTo(slackertest.ResponseURLMethod); len(calls) != 1 {
+		t.Errorf("got %d calls to the response_url, want 1", len(calls))
+	}
+}
+
+func TestRunSuggestion(t *testing.T) {
+	api := slackertest.NewServer()
+	defer api.Close()
+	var lock sync.Mutex
+	depth, maxDepth, runs := 0, 0, 0
+	handler := newTestBot(t, api, func(bot *slacker.Slacker) {
+		bot.Use(func(next slacker.Handler) slacker.Handler {
+			return func(request slacker.Request, response slacker.ResponseWriter) {
+				lock.Lock()
+				depth++
+				runs++
+				if depth > maxDepth {
+					maxDepth = depth
+				}
+				lock.Unlock()
+				defer func() {
+					lock.Lock()
+					defer lock.Unlock()
+					depth--
+				}()
+				next(request, response)
+			}
+		})
+	})
+
+	if got := run(t, api, handler, "verison", 1); !strings.Contains(got, "Did you mean `version`?") {
+		t.Fatalf("replied %q, want a suggestion", got)
+	}
+
+	click, _ := json.Marshal(map[string]interface{}{
+		"type":         "block_actions",
+		"user":         map[string]interface{}{"id": testUser},
+		"channel":      map[string]interface{}{"id": testChannel},
+		"message":      map[string]interface{}{"ts": "1500000000.000001"},
+		"response_url": api.ResponseURL(),
+		"actions":      []map[string]interface{}{{"action_id": "slacker.run-suggestion", "block_id": "suggestion", "value": "version"}},
+	})
+	if err := slackertest.InjectInteraction(handler, click); err != nil {
+		t.Fatal(err)
+	}
+	calls, err := api.WaitForCalls("chat.postMessage", 2, waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := calls[1].Params.Get("text"), "v1"; got != want {
+		t.Errorf("the suggested command replied %q, want %q", got, want)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	// once for the action, once for the command, one after the other
+	if runs != 2 || maxDepth != 1 {
+		t.Errorf("the middleware ran %d times nested %d deep, want 2 times not nested", runs, maxDepth)
+	}
+}
@@ -0,0 +1,165 @@
+package slacker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	jobsCommand   = "jobs"
+	cancelCommand = "cancel"
+
+	timedOutFormat  = "`%s` timed out after %s."
+	cancelledFormat = "Cancelled job %d (`%s`)."
+	noJobsMessage   = "You have no running commands."
+	jobLineFormat   = "%d: `%s`, running for %s"
+)
+
+// job is one running command invocation.
+type job struct {
+	id      int
+	command string
+	user    string
+	channel string
+	text    string
+	started time.Time
+	cancel  context.CancelFunc
+}
+
+// jobRegistry tracks the running command invocations, so users can list and cancel them.
+type jobRegistry struct {
+	lock   sync.Mutex
+	nextID int
+	jobs   map[int]*job
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{jobs: map[int]*job{}}
+}
+
+// start registers a job with a context derived from ctx, cancelled after the
+// timeout if positive. The returned func must be called when the job is done.
+func (r *jobRegistry) start(ctx context.Context, timeout time.Duration, command, user, channel, text string) (context.Context, func()) {
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.nextID++
+	id := r.nextID
+	r.jobs[id] = &job{id: id, command: command, user: user, channel: channel, text: text, started: time.Now(), cancel: cancel}
+
+	return ctx, func() {
+		cancel()
+
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		delete(r.jobs, id)
+	}
+}
+
+// list returns the jobs of the user, oldest first.
+func (r *jobRegistry) list(user string) []job {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var jobs []job
+	for _, j := range r.jobs {
+		if j.user == user {
+			jobs = append(jobs, *j)
+		}
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].id < jobs[k].id })
+	return jobs
+}
+
+// cancel aborts the job if it belongs to the user.
+func (r *jobRegistry) cancel(user string, id int) (job, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	j, ok := r.jobs[id]
+	if !ok || j.user != user {
+		return job{}, fmt.Errorf("you have no running job %d", id)
+	}
+	j.cancel()
+	return *j, nil
+}
+
+// commandTimeout returns the timeout of the command, or the default one.
+func (s *Slacker) commandTimeout(command BotCommand) time.Duration {
+	if command.Definition() != nil && command.Definition().Timeout != 0 {
+		return command.Definition().Timeout
+	}
+	return s.commandTimeoutDefault
+}
+
+// runJob runs the command under a registered job context, and tells the user if it timed out.
+func (s *Slacker) runJob(request Request, response ResponseWriter, run func(request Request)) {
+	command, message := request.Command(), request.Message()
+	ctx, done := s.jobs.start(request.Context(), s.commandTimeout(command), commandName(command), message.User, message.Channel, message.Text)
+	defer done()
+
+	run(withContext(request, ctx))
+
+	if ctx.Err() == context.DeadlineExceeded && request.Context().Err() == nil {
+		if _, err := response.Reply(fmt.Sprintf(timedOutFormat, commandName(command), s.commandTimeout(command))); err != nil {
+			klog.Error(err)
+		}
+	}
+}
+
+// isJobCommand returns true if the text is one of the commands to list and cancel jobs.
+func (s *Slacker) isJobCommand(text string) bool {
+	m := s.matchCommand(text)
+	if m == nil {
+		return false
+	}
+	name := commandName(m.command)
+	return name == jobsCommand || name == cancelCommand
+}
+
+// addJobCommands registers the commands to list and cancel running commands.
+func (s *Slacker) addJobCommands() {
+	s.Command(jobsCommand, &CommandDefinition{
+		Description: "List your running commands.",
+		Handler: func(request Request, response ResponseWriter) {
+			var lines []string
+			for _, j := range s.jobs.list(request.Message().User) {
+				if j.command == jobsCommand {
+					continue
+				}
+				lines = append(lines, fmt.Sprintf(jobLineFormat, j.id, j.text, time.Since(j.started).Truncate(time.Second)))
+			}
+			if len(lines) == 0 {
+				response.Reply(noJobsMessage)
+				return
+			}
+			response.Reply(strings.Join(lines, newLine))
+		},
+	})
+	s.Command(cancelCommand+" <job>", &CommandDefinition{
+		Description: "Cancel one of your running commands.",
+		Example:     "cancel 42",
+		Params:      []Param{{Name: "job", Description: "The job number shown by `jobs`.", Type: ParamInt, Required: true}},
+		Handler: func(request Request, response ResponseWriter) {
+			j, err := s.jobs.cancel(request.Message().User, request.IntegerParam("job", 0))
+			if err != nil {
+				response.ReportError(err)
+				return
+			}
+			response.Reply(fmt.Sprintf(cancelledFormat, j.id, j.text))
+		},
+	})
+}
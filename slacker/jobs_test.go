@@ -0,0 +1,67 @@
+package slacker_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sttts/sttts-bot/slacker"
+	"github.com/sttts/sttts-bot/slackertest"
+)
+
+func TestJobs(t *testing.T) {
+	api := slackertest.NewServer()
+	defer api.Close()
+	handler := newTestBot(t, api, func(bot *slacker.Slacker) {
+		bot.Command("sleep", &slacker.CommandDefinition{
+			Handler: func(request slacker.Request, response slacker.ResponseWriter) {
+				response.Reply("sleeping")
+				<-request.Context().Done()
+				response.Reply("woke up: " + request.Context().Err().Error())
+			},
+		})
+		bot.Command("nap", &slacker.CommandDefinition{
+			Timeout: 50 * time.Millisecond,
+			Handler: func(request slacker.Request, response slacker.ResponseWriter) {
+				<-request.Context().Done()
+			},
+		})
+	})
+
+	// the only worker is busy with sleep, jobs and cancel must not wait for it
+	steps := []struct {
+		text string
+		want string
+	}{
+		{"sleep", "sleeping"},
+		{"jobs", "1: `sleep`"},
+		{"cancel 2", "you have no running job 2"},
+	}
+	for i, step := range steps {
+		if got := run(t, api, handler, step.text, i+1); !strings.Contains(got, step.want) {
+			t.Errorf("%q replied %q, want %q", step.text, got, step.want)
+		}
+	}
+
+	// cancel and the cancelled sleep reply concurrently
+	if err := slackertest.InjectEvent(handler, slackertest.AppMentionEvent(testChannel, testUser, "cancel 1")); err != nil {
+		t.Fatal(err)
+	}
+	calls, err := api.WaitForCalls("chat.postMessage", len(steps)+2, waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replies := map[string]bool{}
+	for _, call := range calls[len(steps):] {
+		replies[call.Params.Get("text")] = true
+	}
+	for _, want := range []string{"Cancelled job 1 (`sleep`).", "woke up: context canceled"} {
+		if !replies[want] {
+			t.Errorf("missing reply %q after cancel, got %v", want, replies)
+		}
+	}
+
+	if got, want := run(t, api, handler, "nap", len(steps)+3), "`nap` timed out after 50ms."; got != want {
+		t.Errorf("nap replied %q, want %q", got, want)
+	}
+}
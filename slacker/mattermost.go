@@ -0,0 +1,413 @@
+package slacker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gorilla/handlers"
+	"k8s.io/klog"
+)
+
+const (
+	mattermostWebhookPath = "/mattermost"
+	mattermostAPIPath     = "api/v4/"
+
+	// mattermostDirectChannel is the type of direct message channels
+	mattermostDirectChannel = "D"
+)
+
+// mattermostClient calls the Mattermost REST API v4 as the bot account.
+type mattermostClient struct {
+	url       string
+	token     string
+	client    *http.Client
+	botUserID string
+}
+
+// mattermostPost is a post as sent to and returned by the Mattermost API.
+type mattermostPost struct {
+	ID        string                 `json:"id,omitempty"`
+	ChannelID string                 `json:"channel_id,omitempty"`
+	RootID    string                 `json:"root_id,omitempty"`
+	Message   string                 `json:"message"`
+	FileIDs   []string               `json:"file_ids,omitempty"`
+	Props     map[string]interface{} `json:"props,omitempty"`
+}
+
+// mattermostWebhook is the payload of an outgoing webhook.
+type mattermostWebhook struct {
+	Token       string `json:"token"`
+	ChannelID   string `json:"channel_id"`
+	UserID      string `json:"user_id"`
+	UserName    string `json:"user_name"`
+	PostID      string `json:"post_id"`
+	Text        string `json:"text"`
+	TriggerWord string `json:"trigger_word"`
+}
+
+func newMattermostClient(opt Options, client *http.Client) *mattermostClient {
+	base := opt.MattermostURL
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return &mattermostClient{url: base + mattermostAPIPath, token: opt.MattermostToken, client: client}
+}
+
+// call sends the request with JSON body in and decodes the JSON response into out, if not nil.
+func (c *mattermostClient) call(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		bs, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(bs)
+	}
+	req, err := http.NewRequest(method, c.url+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, out)
+}
+
+func (c *mattermostClient) do(req *http.Request, out interface{}) error {
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		bs, _ := ioutil.ReadAll(resp.Body)
+		if err := json.Unmarshal(bs, &apiErr); err != nil || len(apiErr.Message) == 0 {
+			apiErr.Message = resp.Status
+		}
+		return fmt.Errorf("mattermost %s %s failed: %s", req.Method, strings.TrimPrefix(req.URL.Path, "/"+mattermostAPIPath), apiErr.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// createPost posts the message and returns its ID.
+func (c *mattermostClient) createPost(post *mattermostPost) (string, error) {
+	var created mattermostPost
+	err := c.call(http.MethodPost, "posts", post, &created)
+	return created.ID, err
+}
+
+// uploadFile uploads the file into the channel and returns its ID, to be attached to a post.
+func (c *mattermostClient) uploadFile(channel, name string, content io.Reader) (string, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if err := w.WriteField("channel_id", channel); err != nil {
+		return empty, err
+	}
+	part, err := w.CreateFormFile("files", name)
+	if err != nil {
+		return empty, err
+	}
+	if _, err := io.Copy(part, content); err != nil {
+		return empty, err
+	}
+	if err := w.Close(); err != nil {
+		return empty, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url+"files", &body)
+	if err != nil {
+		return empty, err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	var uploaded struct {
+		FileInfos []struct {
+			ID string `json:"id"`
+		} `json:"file_infos"`
+	}
+	if err := c.do(req, &uploaded); err != nil {
+		return empty, err
+	}
+	if len(uploaded.FileInfos) == 0 {
+		return empty, errors.New("mattermost returned no uploaded file")
+	}
+	return uploaded.FileInfos[0].ID, nil
+}
+
+// mattermostTransport receives Mattermost outgoing webhooks, and replies through the REST API.
+type mattermostTransport struct {
+	s        *Slacker
+	client   *mattermostClient
+	verifier requestVerifier
+}
+
+func newMattermostTransport(s *Slacker, opt Options) *mattermostTransport {
+	return &mattermostTransport{
+		s:      s,
+		client: newMattermostClient(opt, s.clientDefaults.HTTPClient),
+		// outgoing webhooks are verified by their token
+		verifier: &tokenVerifier{token: opt.MattermostWebhookToken},
+	}
+}
+
+// connect identifies the bot user.
+func (t *mattermostTransport) connect(ctx context.Context) (string, error) {
+	var me struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	}
+	if err := t.client.call(http.MethodGet, "users/me", nil, &me); err != nil {
+		return empty, err
+	}
+	t.client.botUserID = me.ID
+	klog.Infof("Running as Mattermost bot user %s (%s)", me.Username, me.ID)
+	return me.ID, nil
+}
+
+func (t *mattermostTransport) listen(ctx context.Context) error {
+	klog.Infof("sttts-bot up and listening to mattermost on %s", t.s.listenAddress)
+	return t.s.serve(ctx, handlers.LoggingHandler(os.Stdout, t.handler()))
+}
+
+// userGroupMembers fails, Mattermost groups are not supported.
+func (t *mattermostTransport) userGroupMembers(group string) ([]string, error) {
+	return nil, errNoUserGroups
+}
+
+// handler serves the /mattermost endpoint receiving the outgoing webhook.
+func (t *mattermostTransport) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(mattermostWebhookPath, verified(t.verifier, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		webhook, err := parseMattermostWebhook(r, body)
+		if err != nil {
+			klog.Warningf("Failed to parse outgoing webhook: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// replies are posted through the API, not as webhook response
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+
+		if webhook.UserID == t.client.botUserID {
+			return
+		}
+		text := strings.TrimSpace(webhook.Text)
+		if len(webhook.TriggerWord) > 0 && strings.HasPrefix(strings.ToLower(text), strings.ToLower(webhook.TriggerWord)) {
+			text = strings.TrimSpace(text[len(webhook.TriggerWord):])
+		}
+		t.handleMessage(&Message{
+			Platform: MattermostPlatform,
+			ID:       webhook.PostID,
+			User:     webhook.UserID,
+			UserName: webhook.UserName,
+			Channel:  webhook.ChannelID,
+			Text:     text,
+			original: webhook,
+		})
+	}))
+	return mux
+}
+
+// parseMattermostWebhook decodes the payload, which Mattermost sends as JSON or as form depending on the webhook.
+func parseMattermostWebhook(r *http.Request, body []byte) (*mattermostWebhook, error) {
+	var webhook mattermostWebhook
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		err := json.Unmarshal(body, &webhook)
+		return &webhook, err
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	webhook = mattermostWebhook{
+		Token:       values.Get("token"),
+		ChannelID:   values.Get("channel_id"),
+		UserID:      values.Get("user_id"),
+		UserName:    values.Get("user_name"),
+		PostID:      values.Get("post_id"),
+		Text:        values.Get("text"),
+		TriggerWord: values.Get("trigger_word"),
+	}
+	return &webhook, nil
+}
+
+// handleMessage queues the message for the workers. The webhook does not tell
+// the thread of the message nor whether it is direct, so these are looked up
+// before dispatching.
+func (t *mattermostTransport) handleMessage(message *Message) {
+	response := &mattermostResponse{client: t.client, message: message}
+	t.s.submitCommand(message.Text, response, func(ctx context.Context, response *replyRecorder) {
+		var post mattermostPost
+		if err := t.client.call(http.MethodGet, "posts/"+url.PathEscape(message.ID), nil, &post); err != nil {
+			klog.Warningf("Failed to get post %s: %v", message.ID, err)
+		}
+		message.Thread = post.RootID
+
+		var channel struct {
+			Type string `json:"type"`
+		}
+		if err := t.client.call(http.MethodGet, "channels/"+url.PathEscape(message.Channel), nil, &channel); err != nil {
+			klog.Warningf("Failed to get channel %s: %v", message.Channel, err)
+		}
+		message.Direct = channel.Type == mattermostDirectChannel
+
+		t.s.dispatch(ctx, message, response)
+	})
+}
+
+// mattermostResponse implements the ResponseWriter for Mattermost. Blocks are rendered
+// as Markdown, attachments are passed on as Mattermost supports them natively.
+type mattermostResponse struct {
+	client  *mattermostClient
+	message *Message
+
+	// threadReplies is the default of WithThreadReply, from the command definition
+	threadReplies bool
+}
+
+// setThreadReplies sets the default for replies into the thread.
+func (r *mattermostResponse) setThreadReplies(threadReplies bool) {
+	r.threadReplies = threadReplies
+}
+
+// rootID returns the thread to reply into, like messenger.threadTS.
+func (r *mattermostResponse) rootID(thread bool) string {
+	if len(r.message.Thread) > 0 {
+		return r.message.Thread
+	}
+	if thread {
+		return r.message.ID
+	}
+	return empty
+}
+
+// post converts the reply into a Mattermost post.
+func (r *mattermostResponse) post(channel, text string, defaults *ReplyDefaults) *mattermostPost {
+	post := &mattermostPost{ChannelID: channel, Message: markdown(text)}
+	if lines := renderBlocks(defaults.Blocks, markdown); len(lines) > 0 {
+		post.Message = strings.Join(lines, newLine)
+	}
+	if len(defaults.Attachments) > 0 {
+		post.Props = map[string]interface{}{"attachments": defaults.Attachments}
+	}
+	return post
+}
+
+// Reply posts the message into the channel, into the thread like for Slack
+func (r *mattermostResponse) Reply(text string, options ...ReplyOption) (string, error) {
+	defaults := newReplyDefaults(append([]ReplyOption{WithThreadReply(r.threadReplies)}, options...)...)
+	post := r.post(r.message.Channel, text, defaults)
+	post.RootID = r.rootID(defaults.ThreadResponse)
+	return r.client.createPost(post)
+}
+
+// ReplyEphemeral posts a message into the channel which only the user sees
+func (r *mattermostResponse) ReplyEphemeral(text string, options ...ReplyOption) (string, error) {
+	defaults := newReplyDefaults(append([]ReplyOption{WithThreadReply(r.threadReplies)}, options...)...)
+	post := r.post(r.message.Channel, text, defaults)
+	post.RootID = r.rootID(defaults.ThreadResponse)
+
+	var created mattermostPost
+	err := r.client.call(http.MethodPost, "posts/ephemeral", map[string]interface{}{"user_id": r.message.User, "post": post}, &created)
+	return created.ID, err
+}
+
+// Update replaces the text of a post sent before
+func (r *mattermostResponse) Update(id string, text string, options ...ReplyOption) (string, error) {
+	if len(id) == 0 {
+		return empty, errors.New("there is no message to update")
+	}
+	post := r.post(empty, text, newReplyDefaults(options...))
+	patch := map[string]interface{}{"message": post.Message}
+	if post.Props != nil {
+		patch["props"] = post.Props
+	}
+	var patched mattermostPost
+	err := r.client.call(http.MethodPut, "posts/"+url.PathEscape(id)+"/patch", patch, &patched)
+	return patched.ID, err
+}
+
+// Delete removes a post sent before
+func (r *mattermostResponse) Delete(id string) error {
+	return r.client.call(http.MethodDelete, "posts/"+url.PathEscape(id), nil, nil)
+}
+
+// React adds an emoji reaction of the bot to the post which triggered the command
+func (r *mattermostResponse) React(emoji string) error {
+	if len(r.message.ID) == 0 {
+		return errNoMessage
+	}
+	return r.client.call(http.MethodPost, "reactions", map[string]string{
+		"user_id":    r.client.botUserID,
+		"post_id":    r.message.ID,
+		"emoji_name": strings.Trim(emoji, ":"),
+	}, nil)
+}
+
+// unreact removes an emoji reaction of the bot from the post which triggered the command
+func (r *mattermostResponse) unreact(emoji string) error {
+	if len(r.message.ID) == 0 {
+		return errNoMessage
+	}
+	return r.client.call(http.MethodDelete, fmt.Sprintf("users/%s/posts/%s/reactions/%s",
+		url.PathEscape(r.client.botUserID), url.PathEscape(r.message.ID), url.PathEscape(strings.Trim(emoji, ":"))), nil, nil)
+}
+
+// UploadFile shares a file in the channel, into the thread like Reply, and returns the ID of the post showing it
+func (r *mattermostResponse) UploadFile(name string, content io.Reader) (string, error) {
+	id, err := r.client.uploadFile(r.message.Channel, name, content)
+	if err != nil {
+		return empty, err
+	}
+	return r.client.createPost(&mattermostPost{
+		ChannelID: r.message.Channel,
+		RootID:    r.rootID(r.threadReplies),
+		FileIDs:   []string{id},
+	})
+}
+
+// DM sends a direct message to the user
+func (r *mattermostResponse) DM(user string, text string, options ...ReplyOption) (string, error) {
+	var channel struct {
+		ID string `json:"id"`
+	}
+	if err := r.client.call(http.MethodPost, "channels/direct", []string{r.client.botUserID, user}, &channel); err != nil {
+		return empty, err
+	}
+	return r.client.createPost(r.post(channel.ID, text, newReplyDefaults(options...)))
+}
+
+// Progress returns a status message handle for long-running commands
+func (r *mattermostResponse) Progress() Progress {
+	return newProgress(r)
+}
+
+// ReportError posts the formatted error into the channel, into the thread following the same rules as Reply
+func (r *mattermostResponse) ReportError(err error, options ...ReportErrorOption) {
+	defaults := newReportErrorDefaults(append([]ReportErrorOption{WithThreadError(r.threadReplies)}, options...)...)
+	post := &mattermostPost{
+		ChannelID: r.message.Channel,
+		RootID:    r.rootID(defaults.ThreadResponse),
+		Message:   markdown(fmt.Sprintf(errorFormat, err.Error())),
+	}
+	if _, err := r.client.createPost(post); err != nil {
+		klog.Errorf("Failed to report error: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package slacker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testMattermostBot     = "bot1"
+	testMattermostWebhook = "hook-token"
+)
+
+// fakeMattermost serves the parts of the Mattermost API used by the transport,
+// and passes the created posts on.
+func fakeMattermost(t *testing.T, channelTypes map[string]string) (*httptest.Server, <-chan mattermostPost) {
+	posts := make(chan mattermostPost, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/"+mattermostAPIPath)
+		switch {
+		case r.Method == http.MethodGet && path == "users/me":
+			json.NewEncoder(w).Encode(map[string]string{"id": testMattermostBot, "username": "sttts-bot"})
+		case r.Method == http.MethodGet && strings.HasPrefix(path, "posts/"):
+			json.NewEncoder(w).Encode(mattermostPost{ID: strings.TrimPrefix(path, "posts/"), RootID: "root1"})
+		case r.Method == http.MethodGet && strings.HasPrefix(path, "channels/"):
+			json.NewEncoder(w).Encode(map[string]string{"type": channelTypes[strings.TrimPrefix(path, "channels/")]})
+		case r.Method == http.MethodPost && path == "posts":
+			var post mattermostPost
+			if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
+				t.Errorf("invalid post: %v", err)
+			}
+			post.ID = "created"
+			posts <- post
+			json.NewEncoder(w).Encode(post)
+		default:
+			t.Errorf("unexpected call %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, posts
+}
+
+func TestMattermost(t *testing.T) {
+	api, posts := fakeMattermost(t, map[string]string{"dm1": mattermostDirectChannel, "town": "O"})
+	defer api.Close()
+
+	s := NewSlacker(Options{
+		Platform:               MattermostPlatform,
+		MattermostURL:          api.URL,
+		MattermostToken:        "bot-token",
+		MattermostWebhookToken: testMattermostWebhook,
+		Workers:                1,
+		QueueSize:              10,
+		ChannelAllowlist:       true,
+	})
+	s.Command("version", &CommandDefinition{
+		Handler: func(request Request, response ResponseWriter) {
+			response.Reply("v1")
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler, err := s.Handler(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webhook := func(contentType string, body []byte) int {
+		r := httptest.NewRequest(http.MethodPost, mattermostWebhookPath, bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	jsonWebhook := func(token, channel, user, text string) []byte {
+		bs, _ := json.Marshal(mattermostWebhook{Token: token, ChannelID: channel, UserID: user, PostID: "p1", Text: text, TriggerWord: "@sttts-bot"})
+		return bs
+	}
+
+	if code := webhook("application/json", jsonWebhook("wrong", "dm1", "alice", "@sttts-bot version")); code == http.StatusOK {
+		t.Errorf("a wrong webhook token was accepted")
+	}
+	// the bot's own posts are ignored
+	if code := webhook("application/json", jsonWebhook(testMattermostWebhook, "dm1", testMattermostBot, "@sttts-bot version")); code != http.StatusOK {
+		t.Errorf("the webhook returned %d", code)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		want        mattermostPost
+	}{
+		{"direct message as JSON", "application/json", jsonWebhook(testMattermostWebhook, "dm1", "alice", "@sttts-bot version"),
+			mattermostPost{ID: "created", ChannelID: "dm1", RootID: "root1", Message: "v1"}},
+		{"channel not allowed as form", "application/x-www-form-urlencoded", []byte(url.Values{
+			"token": {testMattermostWebhook}, "channel_id": {"town"}, "user_id": {"alice"}, "post_id": {"p2"}, "text": {"version"},
+		}.Encode()),
+			mattermostPost{ID: "created", ChannelID: "town", RootID: "root1", Message: "I am not enabled in this channel. Talk to me in ."}},
+	}
+	for _, tt := range tests {
+		if code := webhook(tt.contentType, tt.body); code != http.StatusOK {
+			t.Errorf("%s: the webhook returned %d", tt.name, code)
+			continue
+		}
+		select {
+		case got := <-posts:
+			if got.ID != tt.want.ID || got.ChannelID != tt.want.ChannelID || got.RootID != tt.want.RootID || got.Message != tt.want.Message {
+				t.Errorf("%s: posted %+v, want %+v", tt.name, got, tt.want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: no reply posted", tt.name)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package slacker
+
+const (
+	// SlackPlatform is the platform of messages received from Slack
+	SlackPlatform = "slack"
+	// MattermostPlatform is the platform of messages received from Mattermost
+	MattermostPlatform = "mattermost"
+	// TerminalPlatform is the platform of commands run with Exec and REPL
+	TerminalPlatform = "terminal"
+)
+
+// Message is a chat message in a platform-neutral form. Rich content of responses
+// is given as Slack Block Kit blocks, see WithBlocks, which platforms without
+// blocks render as text.
+type Message struct {
+	// Platform the message was received from, e.g. SlackPlatform
+	Platform string
+	// ID identifies the message in the channel, i.e. the timestamp in Slack or the post ID in Mattermost. Empty for slash commands
+	ID string
+	// User is the ID of the sender
+	User string
+	// UserName is the name of the sender, if the platform sends it along. Slack does not
+	UserName string
+	// Channel is the ID of the channel the message was sent to
+	Channel string
+	// Thread is the ID of the first message of the thread the message is part of, empty outside of threads
+	Thread string
+	// Text is the message with the mention of the bot removed
+	Text string
+	// Direct is true for direct messages to the bot
+	Direct bool
+
+	// original is the event of the platform the message was received as, e.g. *slackevents.MessageEvent
+	original interface{}
+}
@@ -0,0 +1,44 @@
+package slacker
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+
+	"k8s.io/klog"
+)
+
+// metrics are published via expvar on the /debug/vars endpoint of the metrics address.
+var metrics = expvar.NewMap("slacker")
+
+const (
+	metricEventsDuplicateDropped = "events_duplicate_dropped"
+	metricEventsRetryDropped     = "events_retry_dropped"
+	metricDispatchQueueFull      = "dispatch_queue_full"
+	metricDispatchCommandBusy    = "dispatch_command_busy"
+	metricRateLimited            = "rate_limited"
+	metricSlackAPIRateLimited    = "slack_api_rate_limited"
+)
+
+// ServeMetrics serves /debug/vars on the metrics address, if set, in the background until
+// the context is done. It is separate from the listen address because expvar also exposes
+// the command line and memory stats. Call it once, not for every retry of Listen.
+func (s *Slacker) ServeMetrics(ctx context.Context) {
+	if len(s.metricsAddress) == 0 {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	server := &http.Server{Addr: s.metricsAddress, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		klog.Infof("Serving metrics on %s", s.metricsAddress)
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			klog.Errorf("Failed to serve metrics: %v", err)
+		}
+	}()
+}
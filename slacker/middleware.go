@@ -0,0 +1,58 @@
+package slacker
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// Handler handles a request, like CommandDefinition.Handler
+type Handler func(request Request, response ResponseWriter)
+
+// Middleware wraps a handler, e.g. to log, measure or guard its execution
+type Middleware func(next Handler) Handler
+
+// chain wraps the handler with the registered middlewares
+func (s *Slacker) chain(handler Handler) Handler {
+	for i := len(s.middlewares) - 1; i >= 0; i-- {
+		handler = s.middlewares[i](handler)
+	}
+	return handler
+}
+
+// RecoverPanics replies with an error instead of crashing the process when a handler panics
+func RecoverPanics() Middleware {
+	return func(next Handler) Handler {
+		return func(request Request, response ResponseWriter) {
+			defer func() {
+				if r := recover(); r != nil {
+					klog.Errorf("Handler for %q panicked: %v\n%s", commandUsage(request), r, debug.Stack())
+					response.ReportError(fmt.Errorf("internal error, the command crashed"))
+				}
+			}()
+			next(request, response)
+		}
+	}
+}
+
+// LogLatency logs how long every command took
+func LogLatency() Middleware {
+	return func(next Handler) Handler {
+		return func(request Request, response ResponseWriter) {
+			start := time.Now()
+			defer func() {
+				klog.Infof("Command %q by %s took %v", commandUsage(request), request.Message().User, time.Since(start))
+			}()
+			next(request, response)
+		}
+	}
+}
+
+func commandUsage(request Request) string {
+	if request.Command() == nil {
+		return empty
+	}
+	return request.Command().Usage()
+}
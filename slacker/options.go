@@ -2,31 +2,233 @@ package slacker
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 )
 
 type Options struct {
-	Token             string
-	ListenAddress     string
+	// Platform is the chat platform to connect to, SlackPlatform or MattermostPlatform.
+	Platform string
+
+	Token    string
+	AppToken string
+	// Transport is how Slack events are received, EventsTransport or SocketModeTransport.
+	Transport     string
+	ListenAddress string
+	// MetricsAddress is where /debug/vars is served. Empty disables it.
+	MetricsAddress    string
+	SigningSecret     string
+	SignatureMaxAge   time.Duration
 	VerificationToken string
+
+	// MattermostURL is the Mattermost server for MattermostPlatform, e.g. https://chat.example.com/.
+	MattermostURL string
+	// MattermostToken is the access token of the Mattermost bot account.
+	MattermostToken string
+	// MattermostWebhookToken is the token of the outgoing webhook sending the messages to the bot.
+	MattermostWebhookToken string
+
+	// APIURL is the Slack Web API endpoint, ending in a slash. Empty means the real Slack API.
+	APIURL string
+	// Debug logs the Slack API calls.
+	Debug bool
+	// Proxy is the URL of the HTTP proxy for the platform API. Empty means the one from the environment.
+	Proxy string
+	// HTTPTimeout limits connecting to the platform API and waiting for its responses. Zero means no timeout.
+	HTTPTimeout time.Duration
+	// RateLimitRetries is how often rate limited API calls are retried, if
+	// the platform asks to wait no longer than RateLimitMaxWait.
+	RateLimitRetries int
+	RateLimitMaxWait time.Duration
+
+	// AllowVerificationToken enables the deprecated verification token check
+	// if no signing secret is set.
+	AllowVerificationToken bool
+
+	// EventDedupTTL is how long event IDs are remembered to drop retried
+	// events. Zero disables deduplication.
+	EventDedupTTL  time.Duration
+	EventDedupSize int
+	// IgnoreRetries drops every event Slack marks as a retry.
+	IgnoreRetries bool
+
+	// Workers is the number of commands handled in parallel, QueueSize the
+	// number of waiting ones before the bot replies that it is busy.
+	Workers   int
+	QueueSize int
+
+	// UserRateLimit and ChannelRateLimit are token buckets across all
+	// commands, in the form "<burst>/<interval>". Empty means unlimited.
+	UserRateLimit    string
+	ChannelRateLimit string
+
+	// RequireMentionIn* define the AddressingPolicy, i.e. where messages must mention the bot to be handled as command.
+	RequireMentionInChannels bool
+	RequireMentionInDMs      bool
+	RequireMentionInThreads  bool
+
+	// RBACConfig is the path to the role configuration, see RBACConfig. Empty disables RBAC.
+	RBACConfig string
+
+	// ChannelAllowlist restricts the bot to channels configured via the channel commands.
+	ChannelAllowlist bool
+
+	// DrainTimeout is how long to wait for running commands on shutdown
+	// before cancelling them.
+	DrainTimeout time.Duration
+
+	// CommandTimeout cancels commands without own timeout after this duration. Zero means no timeout.
+	CommandTimeout time.Duration
 }
 
 func AddFlags(opt *Options) {
-	pflag.StringVar(&opt.ListenAddress, "slack-listen", "0.0.0.0:3000", "Address and port to listen on.")
+	pflag.StringVar(&opt.Platform, "platform", SlackPlatform, "Chat platform to connect to, \"slack\" or \"mattermost\" (outgoing webhook on /mattermost).")
+	pflag.StringVar(&opt.Transport, "slack-transport", EventsTransport, "How to receive Slack events, \"events\" (HTTP endpoint) or \"socket\" (Socket Mode).")
+	pflag.StringVar(&opt.MattermostURL, "mattermost-url", "", "URL of the Mattermost server for --platform=mattermost.")
+	pflag.StringVar(&opt.ListenAddress, "listen", "0.0.0.0:3000", "Address and port to listen on.")
+	pflag.StringVar(&opt.MetricsAddress, "metrics-listen", "", "Address and port to serve the metrics on /debug/vars, e.g. 127.0.0.1:9090. Empty disables metrics.")
+	pflag.StringVar(&opt.APIURL, "slack-api-url", "", "Slack Web API endpoint, e.g. of a test server. Empty means https://slack.com/api/.")
+	pflag.BoolVar(&opt.Debug, "slack-debug", false, "Log the Slack API calls.")
+	pflag.StringVar(&opt.Proxy, "proxy", "", "URL of the HTTP proxy for the platform API. Empty means the one from HTTPS_PROXY.")
+	pflag.DurationVar(&opt.HTTPTimeout, "http-timeout", 30*time.Second, "Timeout for connecting to the platform API and for its responses. Zero means no timeout.")
+	pflag.IntVar(&opt.RateLimitRetries, "api-rate-limit-retries", 3, "How often to retry platform API calls rejected with 429 Too Many Requests.")
+	pflag.DurationVar(&opt.RateLimitMaxWait, "api-rate-limit-max-wait", time.Minute, "Maximum Retry-After of a rate limited platform API call to wait for before retrying.")
+	pflag.DurationVar(&opt.SignatureMaxAge, "slack-signature-max-age", 5*time.Minute, "Maximum age of a signed Slack request before it is rejected as a replay.")
+	pflag.BoolVar(&opt.AllowVerificationToken, "slack-allow-verification-token", false, "Fall back to the deprecated SLACK_VERIFICATION_TOKEN if SLACK_SIGNING_SECRET is not set.")
+	pflag.DurationVar(&opt.EventDedupTTL, "slack-event-dedup-ttl", 10*time.Minute, "How long to remember Slack event IDs to drop retried events. Zero disables deduplication.")
+	pflag.IntVar(&opt.EventDedupSize, "slack-event-dedup-size", 1000, "Maximum number of Slack event IDs to remember for deduplication.")
+	pflag.BoolVar(&opt.IgnoreRetries, "slack-ignore-retries", false, "Drop all events which Slack marks as retries.")
+	pflag.IntVar(&opt.Workers, "workers", 4, "Number of commands handled in parallel.")
+	pflag.IntVar(&opt.QueueSize, "queue-size", 100, "Number of queued messages before the bot replies that it is busy.")
+	pflag.DurationVar(&opt.DrainTimeout, "drain-timeout", 30*time.Second, "How long to wait for running commands on shutdown before cancelling them.")
+	pflag.DurationVar(&opt.CommandTimeout, "command-timeout", 10*time.Minute, "How long commands may run before they are cancelled, unless they define their own timeout. Zero means no timeout.")
+	pflag.StringVar(&opt.UserRateLimit, "rate-limit-user", "", "Commands a user may run, as <burst>/<interval>, e.g. 10/1m. Empty means unlimited.")
+	pflag.StringVar(&opt.ChannelRateLimit, "rate-limit-channel", "", "Commands which may run in a channel, as <burst>/<interval>, e.g. 30/1m. Empty means unlimited.")
+	pflag.BoolVar(&opt.RequireMentionInChannels, "slack-require-mention-in-channels", true, "Only handle Slack channel messages which mention the bot.")
+	pflag.BoolVar(&opt.RequireMentionInDMs, "slack-require-mention-in-dms", false, "Only handle Slack direct messages which mention the bot.")
+	pflag.BoolVar(&opt.RequireMentionInThreads, "slack-require-mention-in-threads", false, "Only handle Slack messages which mention the bot in threads the bot takes part in.")
+	pflag.BoolVar(&opt.ChannelAllowlist, "channel-allowlist", false, "Only answer in channels which have been configured with the channel commands. Direct messages are always answered.")
+	pflag.StringVar(&opt.RBACConfig, "rbac-config", "", "Path to a YAML file defining roles for role-based access control of commands. Without it, commands requiring a role, like changing the channel configuration, are disabled.")
+
+	// --listen was --slack-listen before Mattermost was supported
+	deprecatedFlag("slack-listen", "listen")
 
 	opt.Token = os.Getenv("SLACK_BOT_TOKEN")
+	opt.AppToken = os.Getenv("SLACK_APP_TOKEN")
+	opt.SigningSecret = os.Getenv("SLACK_SIGNING_SECRET")
 	opt.VerificationToken = os.Getenv("SLACK_VERIFICATION_TOKEN")
+	opt.MattermostToken = os.Getenv("MATTERMOST_BOT_TOKEN")
+	opt.MattermostWebhookToken = os.Getenv("MATTERMOST_WEBHOOK_TOKEN")
+}
+
+// deprecatedFlag adds the old name of a renamed flag, hidden and printing a deprecation warning when used.
+func deprecatedFlag(old, name string) {
+	flag := pflag.Lookup(name)
+	pflag.CommandLine.AddFlag(&pflag.Flag{Name: old, Usage: flag.Usage, Value: flag.Value, DefValue: flag.DefValue, NoOptDefVal: flag.NoOptDefVal})
+	pflag.CommandLine.MarkDeprecated(old, fmt.Sprintf("use --%s instead", name))
 }
 
 func ValidateOptions(opt *Options) error {
-	if len(opt.Token) == 0 {
+	switch {
+	case opt.Platform == MattermostPlatform:
+		if len(opt.MattermostURL) == 0 {
+			return fmt.Errorf("--mattermost-url must be set for --platform=%s", MattermostPlatform)
+		}
+		if _, err := url.Parse(opt.MattermostURL); err != nil {
+			return fmt.Errorf("invalid --mattermost-url: %v", err)
+		}
+		if len(opt.MattermostToken) == 0 {
+			return fmt.Errorf("the environment variable MATTERMOST_BOT_TOKEN must be set for --platform=%s", MattermostPlatform)
+		}
+		if len(opt.MattermostWebhookToken) == 0 {
+			return fmt.Errorf("the environment variable MATTERMOST_WEBHOOK_TOKEN must be set for --platform=%s", MattermostPlatform)
+		}
+	case opt.Platform != SlackPlatform:
+		return fmt.Errorf("invalid --platform %q, must be %q or %q", opt.Platform, SlackPlatform, MattermostPlatform)
+	case len(opt.Token) == 0:
 		return fmt.Errorf("the environment variable SLACK_BOT_TOKEN must be set")
 	}
-	if len(opt.VerificationToken) == 0 {
-		return fmt.Errorf("the environment variable SLACK_VERIFICATION_TOKEN must be set")
+	if err := ValidateCommandOptions(opt); err != nil {
+		return err
+	}
+
+	if opt.EventDedupTTL < 0 {
+		return fmt.Errorf("--slack-event-dedup-ttl must not be negative")
+	}
+	if opt.EventDedupTTL > 0 && opt.EventDedupSize <= 0 {
+		return fmt.Errorf("--slack-event-dedup-size must be positive")
+	}
+
+	if opt.Workers <= 0 {
+		return fmt.Errorf("--workers must be positive")
+	}
+	if opt.DrainTimeout < 0 {
+		return fmt.Errorf("--drain-timeout must not be negative")
+	}
+	if len(opt.APIURL) > 0 && !strings.HasSuffix(opt.APIURL, "/") {
+		return fmt.Errorf("--slack-api-url must end with a slash")
+	}
+	if len(opt.Proxy) > 0 {
+		if _, err := url.Parse(opt.Proxy); err != nil {
+			return fmt.Errorf("invalid --proxy: %v", err)
+		}
+	}
+	if opt.HTTPTimeout < 0 {
+		return fmt.Errorf("--http-timeout must not be negative")
+	}
+	if opt.RateLimitRetries < 0 {
+		return fmt.Errorf("--api-rate-limit-retries must not be negative")
+	}
+	if opt.QueueSize < 0 {
+		return fmt.Errorf("--queue-size must not be negative")
+	}
+
+	if opt.Platform == MattermostPlatform {
+		// outgoing webhooks are verified by their token
+		return nil
+	}
+	switch opt.Transport {
+	case EventsTransport:
+	case SocketModeTransport:
+		if len(opt.AppToken) == 0 {
+			return fmt.Errorf("the environment variable SLACK_APP_TOKEN must be set for --slack-transport=%s", SocketModeTransport)
+		}
+		// Socket Mode connections are authenticated by the app token, no signature to verify.
+		return nil
+	default:
+		return fmt.Errorf("invalid --slack-transport %q, must be %q or %q", opt.Transport, EventsTransport, SocketModeTransport)
+	}
+
+	if opt.SignatureMaxAge <= 0 {
+		return fmt.Errorf("--slack-signature-max-age must be positive")
+	}
+	if len(opt.SigningSecret) == 0 {
+		if !opt.AllowVerificationToken {
+			return fmt.Errorf("the environment variable SLACK_SIGNING_SECRET must be set")
+		}
+		if len(opt.VerificationToken) == 0 {
+			return fmt.Errorf("the environment variable SLACK_SIGNING_SECRET or SLACK_VERIFICATION_TOKEN must be set")
+		}
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// ValidateCommandOptions validates the options which apply to running commands, also
+// without Slack connection, e.g. with Slacker.Exec.
+func ValidateCommandOptions(opt *Options) error {
+	if _, err := ParseRateLimit(opt.UserRateLimit); err != nil {
+		return fmt.Errorf("invalid --rate-limit-user: %v", err)
+	}
+	if _, err := ParseRateLimit(opt.ChannelRateLimit); err != nil {
+		return fmt.Errorf("invalid --rate-limit-channel: %v", err)
+	}
+	if opt.CommandTimeout < 0 {
+		return fmt.Errorf("--command-timeout must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package slacker
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shomali11/proper"
+)
+
+// ParamType is the kind of value a command parameter takes. Values are validated
+// and normalized before the handler runs, e.g. a bug ID "rhbz#123" becomes "123".
+type ParamType string
+
+const (
+	// ParamString is any text, the default
+	ParamString ParamType = ""
+	// ParamInt is an integer, read with Request.IntegerParam
+	ParamInt ParamType = "int"
+	// ParamBugID is a Bugzilla bug ID like 1234567, #1234567, rhbz#1234567 or a show_bug.cgi link, read with Request.IntegerParam
+	ParamBugID ParamType = "bug"
+	// ParamEnum is one of Param.Values, case-insensitively
+	ParamEnum ParamType = "enum"
+	// ParamEmail is an e-mail address, also as Slack formats it, i.e. <mailto:a@example.com|a@example.com>
+	ParamEmail ParamType = "email"
+	// ParamDuration is a Go duration like 90m or 2h, or a number of days like 3d, read with Request.DurationParam
+	ParamDuration ParamType = "duration"
+	// ParamDate is a date like 2020-05-31, today or yesterday, read with Request.DateParam
+	ParamDate ParamType = "date"
+	// ParamList is a comma separated list, read with Request.ListParam. With Param.Values, only those are allowed
+	ParamList ParamType = "list"
+	// ParamUser is a user mention like <@U012AB3CD> or a plain user ID, normalized to the user ID
+	ParamUser ParamType = "user"
+)
+
+const (
+	dateLayout = "2006-01-02"
+
+	invalidParamFormat = "invalid `%s` %q, expected %s. Usage: %s"
+	missingParamFormat = "missing `%s`, expected %s. Usage: %s"
+)
+
+var (
+	bugIDPattern   = regexp.MustCompile(`^(?i:rhbz|bz|bug)?#?(\d+)$`)
+	bugURLPattern  = regexp.MustCompile(`^<?https?://[^?]*show_bug\.cgi\?id=(\d+)(\|[^>]*)?>?$`)
+	mailtoPattern  = regexp.MustCompile(`^<mailto:([^|>]+)(\|[^>]*)?>$`)
+	userPattern    = regexp.MustCompile(`^(?:<@([UW][A-Z0-9]+)(?:\|[^>]*)?>|([UW][A-Z0-9]+))$`)
+	daysPattern    = regexp.MustCompile(`^(\d+)d$`)
+	listSeparators = regexp.MustCompile(`\s*,\s*`)
+)
+
+// expected describes the accepted values of the parameter for help and error messages.
+func (p Param) expected() string {
+	switch p.Type {
+	case ParamInt:
+		return "an integer"
+	case ParamBugID:
+		return "a bug ID like 1234567 or rhbz#1234567"
+	case ParamEnum:
+		return "one of " + strings.Join(p.Values, ", ")
+	case ParamEmail:
+		return "an e-mail address"
+	case ParamDuration:
+		return "a duration like 90m, 2h or 3d"
+	case ParamDate:
+		return "a date like 2020-05-31, today or yesterday"
+	case ParamList:
+		if len(p.Values) > 0 {
+			return "a comma separated list of " + strings.Join(p.Values, ", ")
+		}
+		return "a comma separated list"
+	case ParamUser:
+		return "a user mention like @alice"
+	default:
+		return "a text"
+	}
+}
+
+// normalize validates the value and returns it in canonical form.
+func (p Param) normalize(value string) (string, bool) {
+	switch p.Type {
+	case ParamInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return value, false
+		}
+	case ParamBugID:
+		for _, pattern := range []*regexp.Regexp{bugIDPattern, bugURLPattern} {
+			if match := pattern.FindStringSubmatch(value); match != nil {
+				return match[1], match[1] != "0"
+			}
+		}
+		return value, false
+	case ParamEnum:
+		for _, allowed := range p.Values {
+			if strings.EqualFold(value, allowed) {
+				return allowed, true
+			}
+		}
+		return value, false
+	case ParamEmail:
+		if match := mailtoPattern.FindStringSubmatch(value); match != nil {
+			value = match[1]
+		}
+		address, err := mail.ParseAddress(value)
+		if err != nil {
+			return value, false
+		}
+		return address.Address, true
+	case ParamDuration:
+		if _, ok := parseDuration(value); !ok {
+			return value, false
+		}
+	case ParamDate:
+		date, ok := parseDate(value, time.Now())
+		if !ok {
+			return value, false
+		}
+		return date.Format(dateLayout), true
+	case ParamList:
+		items := listSeparators.Split(strings.Trim(value, ", "), -1)
+		if len(p.Values) > 0 {
+			enum := Param{Type: ParamEnum, Values: p.Values}
+			for i, item := range items {
+				normalized, ok := enum.normalize(item)
+				if !ok {
+					return value, false
+				}
+				items[i] = normalized
+			}
+		}
+		return strings.Join(items, ","), true
+	case ParamUser:
+		match := userPattern.FindStringSubmatch(value)
+		if match == nil {
+			return value, false
+		}
+		return match[1] + match[2], true
+	}
+	return value, true
+}
+
+// validateParams checks the parameters against the command definition, and
+// returns them normalized, or an error naming the bad parameter. Flags not
+// shadowed by a parameter are added, with multiple values joined by commas.
+func validateParams(command BotCommand, parameters *proper.Properties, flags map[string][]string) (*proper.Properties, error) {
+	definition := command.Definition()
+	if definition == nil || (len(definition.Params) == 0 && len(flags) == 0) {
+		return parameters, nil
+	}
+	params := map[string]Param{}
+	for _, param := range definition.Params {
+		params[param.Name] = param
+	}
+
+	normalized := map[string]string{}
+	for _, token := range command.Tokenize() {
+		if !token.IsParameter() {
+			continue
+		}
+		value := strings.TrimSpace(parameters.StringParam(token.Word, empty))
+		param, ok := params[token.Word]
+		switch {
+		case !ok:
+		case len(value) == 0 && param.Required:
+			return nil, fmt.Errorf(missingParamFormat, param.Name, param.expected(), fmt.Sprintf(codeMessageFormat, command.Usage()))
+		case len(value) > 0:
+			var valid bool
+			if value, valid = param.normalize(value); !valid {
+				return nil, fmt.Errorf(invalidParamFormat, param.Name, value, param.expected(), fmt.Sprintf(codeMessageFormat, command.Usage()))
+			}
+		}
+		if len(value) > 0 {
+			normalized[token.Word] = value
+		}
+	}
+	for name, values := range flags {
+		if _, ok := normalized[name]; !ok {
+			normalized[name] = strings.Join(values, ",")
+		}
+	}
+	return proper.NewProperties(normalized), nil
+}
+
+// parseDuration parses Go durations, and days like "3d" which Go does not know.
+func parseDuration(value string) (time.Duration, bool) {
+	if match := daysPattern.FindStringSubmatch(value); match != nil {
+		days, _ := strconv.Atoi(match[1])
+		return time.Duration(days) * 24 * time.Hour, true
+	}
+	d, err := time.ParseDuration(value)
+	return d, err == nil
+}
+
+// parseDate parses ISO dates and the words today and yesterday, relative to now.
+func parseDate(value string, now time.Time) (time.Time, bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch strings.ToLower(value) {
+	case "today":
+		return today, true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true
+	}
+	date, err := time.ParseInLocation(dateLayout, value, now.Location())
+	return date, err == nil
+}
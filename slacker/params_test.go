@@ -0,0 +1,81 @@
+package slacker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shomali11/proper"
+)
+
+func TestParamNormalize(t *testing.T) {
+	tests := []struct {
+		param Param
+		value string
+		want  string
+		valid bool
+	}{
+		{Param{Type: ParamString}, "anything goes", "anything goes", true},
+		{Param{Type: ParamInt}, "42", "42", true},
+		{Param{Type: ParamInt}, "forty-two", "forty-two", false},
+		{Param{Type: ParamBugID}, "rhbz#1234567", "1234567", true},
+		{Param{Type: ParamBugID}, "#1234567", "1234567", true},
+		{Param{Type: ParamBugID}, "<https://bugzilla.redhat.com/show_bug.cgi?id=1234567|link>", "1234567", true},
+		{Param{Type: ParamBugID}, "0", "0", false},
+		{Param{Type: ParamEnum, Values: []string{"NEW", "ASSIGNED"}}, "assigned", "ASSIGNED", true},
+		{Param{Type: ParamEnum, Values: []string{"NEW", "ASSIGNED"}}, "CLOSED", "CLOSED", false},
+		{Param{Type: ParamEmail}, "<mailto:a@example.com|a@example.com>", "a@example.com", true},
+		{Param{Type: ParamEmail}, "nobody", "nobody", false},
+		{Param{Type: ParamDuration}, "3d", "3d", true},
+		{Param{Type: ParamDuration}, "soon", "soon", false},
+		{Param{Type: ParamDate}, "2020-05-31", "2020-05-31", true},
+		{Param{Type: ParamDate}, "31.05.2020", "31.05.2020", false},
+		{Param{Type: ParamList, Values: []string{"NEW", "POST"}}, "new, post", "NEW,POST", true},
+		{Param{Type: ParamList, Values: []string{"NEW", "POST"}}, "new,closed", "new,closed", false},
+		{Param{Type: ParamUser}, "<@U012AB3CD|alice>", "U012AB3CD", true},
+		{Param{Type: ParamUser}, "W012AB3CD", "W012AB3CD", true},
+		{Param{Type: ParamUser}, "@alice", "@alice", false},
+	}
+	for _, tt := range tests {
+		got, valid := tt.param.normalize(tt.value)
+		if got != tt.want || valid != tt.valid {
+			t.Errorf("%s normalize(%q) = %q, %v, want %q, %v", tt.param.Type, tt.value, got, valid, tt.want, tt.valid)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	if d, ok := parseDuration("2d"); !ok || d != 48*time.Hour {
+		t.Errorf("parseDuration(2d) = %v, %v", d, ok)
+	}
+	if d, ok := parseDuration("90m"); !ok || d != 90*time.Minute {
+		t.Errorf("parseDuration(90m) = %v, %v", d, ok)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	now := time.Date(2020, 6, 1, 15, 4, 5, 0, time.UTC)
+	if date, ok := parseDate("yesterday", now); !ok || !date.Equal(time.Date(2020, 5, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseDate(yesterday) = %v, %v", date, ok)
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	cmd := NewBotCommand("cancel <job>", &CommandDefinition{Params: []Param{{Name: "job", Type: ParamInt, Required: true}}})
+
+	if _, err := validateParams(cmd, proper.NewProperties(map[string]string{}), nil); err == nil {
+		t.Errorf("missing required parameter was accepted")
+	}
+	if _, err := validateParams(cmd, proper.NewProperties(map[string]string{"job": "x"}), nil); err == nil {
+		t.Errorf("invalid integer was accepted")
+	}
+	parameters, err := validateParams(cmd, proper.NewProperties(map[string]string{"job": " 3 "}), map[string][]string{"verbose": {"true"}})
+	if err != nil {
+		t.Fatalf("valid parameter was rejected: %v", err)
+	}
+	if got := parameters.StringParam("job", empty); got != "3" {
+		t.Errorf("job = %q, want 3", got)
+	}
+	if got := parameters.StringParam("verbose", empty); got != "true" {
+		t.Errorf("flag verbose = %q, want true", got)
+	}
+}
@@ -0,0 +1,116 @@
+package slacker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	progressReaction = "hourglass_flowing_sand"
+	doneReaction     = "white_check_mark"
+	failedReaction   = "x"
+
+	// Slack allows roughly one message update per second
+	minProgressInterval = time.Second
+)
+
+// Progress is a status message of a long-running command, updated in place.
+// While it runs, the triggering message shows a spinner reaction.
+type Progress interface {
+	// Update posts the status message the first time, and replaces it afterwards.
+	// Updates quicker than Slack allows are skipped.
+	Update(status string) error
+	// Done replaces the status message with the result.
+	Done(text string, options ...ReplyOption) error
+	// Fail replaces the status message with the error.
+	Fail(err error) error
+}
+
+// unreacter is implemented by responses which can remove their reactions again.
+type unreacter interface {
+	unreact(emoji string) error
+}
+
+type progress struct {
+	response ResponseWriter
+
+	lock      sync.Mutex
+	timestamp string
+	posted    bool
+	reacted   bool
+	updated   time.Time
+	finished  bool
+}
+
+func newProgress(response ResponseWriter) Progress {
+	return &progress{response: response}
+}
+
+// Update posts or replaces the status message
+func (p *progress) Update(status string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.finished || (p.posted && time.Since(p.updated) < minProgressInterval) {
+		return nil
+	}
+	return p.set(status)
+}
+
+// Done replaces the status message with the result and the spinner with a check mark
+func (p *progress) Done(text string, options ...ReplyOption) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.finish(doneReaction)
+	return p.set(text, options...)
+}
+
+// Fail replaces the status message with the error and the spinner with a cross
+func (p *progress) Fail(err error) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.finish(failedReaction)
+	return p.set(fmt.Sprintf(errorFormat, err.Error()))
+}
+
+func (p *progress) set(text string, options ...ReplyOption) error {
+	var err error
+	if !p.posted {
+		if !p.finished {
+			err := p.response.React(progressReaction)
+			if err != nil && err != errNoMessage {
+				klog.Warningf("Failed to add progress reaction: %v", err)
+			}
+			p.reacted = err == nil
+		}
+		p.timestamp, err = p.response.Reply(text, options...)
+		p.posted = err == nil
+	} else {
+		_, err = p.response.Update(p.timestamp, text, options...)
+	}
+	p.updated = time.Now()
+	return err
+}
+
+// finish swaps the spinner reaction for the final one.
+func (p *progress) finish(reaction string) {
+	if p.finished {
+		return
+	}
+	p.finished = true
+
+	if u, ok := p.response.(unreacter); ok && p.reacted {
+		if err := u.unreact(progressReaction); err != nil {
+			klog.Warningf("Failed to remove progress reaction: %v", err)
+		}
+	}
+	// there is nothing to react to e.g. for slash commands
+	if err := p.response.React(reaction); err != nil && err != errNoMessage {
+		klog.Warningf("Failed to add %s reaction: %v", reaction, err)
+	}
+}
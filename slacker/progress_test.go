@@ -0,0 +1,57 @@
+package slacker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	response := &recordingResponse{}
+	progress := response.Progress()
+
+	progress.Update("querying")
+	// too quick for Slack, skipped
+	progress.Update("1/2 done")
+	progress.Done("result")
+	// finished, skipped
+	progress.Update("2/2 done")
+
+	want := []string{"querying", "result"}
+	if got := response.Messages(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("posted %q, want %q", got, want)
+	}
+
+	response = &recordingResponse{}
+	response.Progress().Fail(errors.New("bugzilla is down"))
+	want = []string{fmt.Sprintf(errorFormat, "bugzilla is down")}
+	if got := response.Messages(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("posted %q, want %q", got, want)
+	}
+}
+
+func TestProgressFailureIsReply(t *testing.T) {
+	s := NewSlacker(Options{Workers: 1, QueueSize: 1, DrainTimeout: 10 * time.Millisecond})
+	stop := s.dispatcher.start()
+	defer stop()
+
+	started := make(chan struct{})
+	response := &recordingResponse{}
+	s.submit(response, func(ctx context.Context, recorder *replyRecorder) {
+		progress := recorder.Progress()
+		progress.Update("querying")
+		close(started)
+		<-ctx.Done()
+		progress.Fail(ctx.Err())
+	})
+	<-started
+	s.drain()
+
+	// the failure tells the user already, there is no restart message on top
+	want := []string{"querying", fmt.Sprintf(errorFormat, context.Canceled.Error())}
+	if got := response.Messages(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("posted %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,229 @@
+package slacker
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	"github.com/sttts/sttts-bot/store"
+	v1 "github.com/sttts/sttts-bot/store/v1"
+)
+
+const (
+	rateLimitedFormat = "Whoa, slow down! Please try again in %s."
+
+	// maxIdleBuckets is the number of buckets kept before full ones are pruned
+	maxIdleBuckets = 1000
+)
+
+// RateLimit is a token bucket: Burst invocations at once, refilled by one every Every
+type RateLimit struct {
+	Burst int
+	Every time.Duration
+}
+
+// ParseRateLimit parses "<burst>/<interval>", e.g. "5/1m". The empty string means no limit
+func ParseRateLimit(s string) (*RateLimit, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid rate limit %q, expected <burst>/<interval>, e.g. 5/1m", s)
+	}
+	burst, err := strconv.Atoi(parts[0])
+	if err != nil || burst <= 0 {
+		return nil, fmt.Errorf("invalid burst in rate limit %q, expected a positive integer", s)
+	}
+	every, err := time.ParseDuration(parts[1])
+	if err != nil || every <= 0 {
+		return nil, fmt.Errorf("invalid interval in rate limit %q, expected a positive duration", s)
+	}
+	return &RateLimit{Burst: burst, Every: every}, nil
+}
+
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%d/%v", l.Burst, l.Every)
+}
+
+type bucket struct {
+	limit   RateLimit
+	tokens  float64
+	updated time.Time
+}
+
+// refill adds the tokens accumulated since the last update
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated)
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+float64(elapsed)/float64(b.limit.Every))
+	}
+	b.updated = now
+}
+
+// wait returns how long until the bucket has a token
+func (b *bucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(b.limit.Every))
+}
+
+// rateLimiter keeps token buckets per user, per channel and per command and user.
+type rateLimiter struct {
+	user    *RateLimit
+	channel *RateLimit
+	now     func() time.Time
+
+	lock    sync.Mutex
+	buckets map[string]*bucket
+	dirty   bool // buckets changed since the last flush
+
+	store store.Store
+}
+
+func newRateLimiter(user, channel *RateLimit) *rateLimiter {
+	return &rateLimiter{
+		user:    user,
+		channel: channel,
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+	}
+}
+
+// persistTo loads bucket state from the store and records changes there.
+func (l *rateLimiter) persistTo(st store.Store) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.store = st
+	st.ReadState(func(state *v1.State) {
+		if state == nil || state.RateLimits == nil {
+			return
+		}
+		for key, b := range state.RateLimits.Buckets {
+			// the limit is filled in on first use from the current configuration
+			l.buckets[key] = &bucket{tokens: b.Tokens, updated: b.Updated.Time}
+		}
+	})
+}
+
+// take consumes a token from all buckets applying to the invocation. If any of
+// them is empty, nothing is consumed and the time to wait is returned.
+func (l *rateLimiter) take(user, channel string, cmd BotCommand) (time.Duration, bool) {
+	type limited struct {
+		key   string
+		limit *RateLimit
+	}
+	candidates := []limited{
+		{"user/" + user, l.user},
+		{"channel/" + channel, l.channel},
+	}
+	if cmd != nil && cmd.Definition() != nil {
+		candidates = append(candidates, limited{"command/" + cmd.Usage() + "/" + user, cmd.Definition().RateLimit})
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	var wait time.Duration
+	var buckets []*bucket
+	for _, c := range candidates {
+		if c.limit == nil {
+			continue
+		}
+		b, ok := l.buckets[c.key]
+		if !ok {
+			b = &bucket{tokens: float64(c.limit.Burst), updated: now}
+			l.buckets[c.key] = b
+		}
+		b.limit = *c.limit
+		b.refill(now)
+		if w := b.wait(); w > wait {
+			wait = w
+		}
+		buckets = append(buckets, b)
+	}
+	if wait > 0 {
+		return wait, false
+	}
+
+	for _, b := range buckets {
+		b.tokens--
+	}
+	l.prune(now)
+	l.dirty = l.dirty || len(buckets) > 0
+	return 0, true
+}
+
+// prune drops buckets which are full again, they are equivalent to new ones.
+func (l *rateLimiter) prune(now time.Time) {
+	if len(l.buckets) <= maxIdleBuckets {
+		return
+	}
+	for key, b := range l.buckets {
+		if b.limit.Every > 0 {
+			b.refill(now)
+		}
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// flush persists the buckets if they changed since the last flush.
+func (l *rateLimiter) flush() {
+	l.lock.Lock()
+	if l.store == nil || !l.dirty {
+		l.lock.Unlock()
+		return
+	}
+	buckets := map[string]v1.TokenBucket{}
+	for key, b := range l.buckets {
+		// buckets idle for a day are full again for any sane limit
+		if time.Since(b.updated) < 24*time.Hour {
+			buckets[key] = v1.TokenBucket{Tokens: b.tokens, Updated: metav1.NewTime(b.updated)}
+		}
+	}
+	l.dirty = false
+	l.lock.Unlock()
+
+	err := l.store.UpdateState(func(old *v1.State) (*v1.State, error) {
+		state := &v1.State{}
+		if old != nil {
+			*state = *old
+		}
+		state.RateLimits = &v1.RateLimits{Buckets: buckets}
+		return state, nil
+	})
+	if err != nil {
+		klog.Warningf("Failed to persist rate limits: %v", err)
+		l.lock.Lock()
+		l.dirty = true
+		l.lock.Unlock()
+	}
+}
+
+// rateLimited replies and returns true if the invocation exceeds a rate limit.
+func (s *Slacker) rateLimited(request Request, response ResponseWriter) bool {
+	if s.rateLimiter == nil {
+		return false
+	}
+
+	wait, ok := s.rateLimiter.take(request.Message().User, request.Message().Channel, request.Command())
+	if ok {
+		return false
+	}
+
+	metrics.Add(metricRateLimited, 1)
+	response.Reply(fmt.Sprintf(rateLimitedFormat, wait.Truncate(time.Second)+time.Second))
+	return true
+}
@@ -0,0 +1,81 @@
+package slacker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    *RateLimit
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"5/1m", &RateLimit{Burst: 5, Every: time.Minute}, false},
+		{"1/30s", &RateLimit{Burst: 1, Every: 30 * time.Second}, false},
+		{"5", nil, true},
+		{"0/1m", nil, true},
+		{"x/1m", nil, true},
+		{"5/0s", nil, true},
+		{"5/soon", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRateLimit(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRateLimit(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("ParseRateLimit(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiterTake(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	l := newRateLimiter(&RateLimit{Burst: 2, Every: time.Minute}, nil)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, ok := l.take("U1", "C1", nil); !ok {
+			t.Fatalf("take %d was limited within the burst", i)
+		}
+	}
+	wait, ok := l.take("U1", "C1", nil)
+	if ok {
+		t.Fatalf("take beyond the burst was not limited")
+	}
+	if wait != time.Minute {
+		t.Errorf("wait = %v, want %v", wait, time.Minute)
+	}
+	if _, ok := l.take("U2", "C1", nil); !ok {
+		t.Errorf("another user was limited")
+	}
+
+	now = now.Add(30 * time.Second)
+	if wait, ok := l.take("U1", "C1", nil); ok || wait != 30*time.Second {
+		t.Errorf("take after half the interval = %v, %v, want 30s, false", wait, ok)
+	}
+	now = now.Add(30 * time.Second)
+	if _, ok := l.take("U1", "C1", nil); !ok {
+		t.Errorf("take after the interval was limited")
+	}
+}
+
+func TestRateLimiterCommand(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	l := newRateLimiter(nil, &RateLimit{Burst: 10, Every: time.Minute})
+	l.now = func() time.Time { return now }
+	cmd := NewBotCommand("bz stats <group>", &CommandDefinition{RateLimit: &RateLimit{Burst: 1, Every: time.Hour}})
+
+	if _, ok := l.take("U1", "C1", cmd); !ok {
+		t.Fatalf("first invocation was limited")
+	}
+	if _, ok := l.take("U1", "C1", cmd); ok {
+		t.Errorf("second invocation of the command was not limited")
+	}
+	if _, ok := l.take("U1", "C1", nil); !ok {
+		t.Errorf("the limit of the command applied to other commands")
+	}
+}
@@ -0,0 +1,316 @@
+package slacker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+
+	"github.com/sttts/sttts-bot/store"
+	v1 "github.com/sttts/sttts-bot/store/v1"
+)
+
+const (
+	// AdminRole may grant and revoke roles
+	AdminRole = "admin"
+
+	userGroupCacheTTL = 5 * time.Minute
+)
+
+// RBACConfig defines the roles, read from the file passed via --rbac-config, e.g.
+//
+//	roles:
+//	  admin:
+//	    users: [U012AB3CD]
+//	  bugzilla:
+//	    userGroups: [S0614TZR7]
+//	    channels: [C024BE91L]
+type RBACConfig struct {
+	Roles map[string]Role `json:"roles"`
+}
+
+// Role is held by the listed users, by members of the listed user groups, and
+// by everybody in the listed channels
+type Role struct {
+	Users      []string `json:"users,omitempty"`
+	UserGroups []string `json:"userGroups,omitempty"`
+	Channels   []string `json:"channels,omitempty"`
+}
+
+// LoadRBACConfig reads the role configuration from a YAML file
+func LoadRBACConfig(path string) (*RBACConfig, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &RBACConfig{}
+	if err := yaml.UnmarshalStrict(bs, config); err != nil {
+		return nil, fmt.Errorf("failed to decode RBAC config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// rbac decides which roles a user has, from the config and from grants made via chat.
+type rbac struct {
+	roles map[string]Role
+
+	lock       sync.Mutex
+	grants     map[string]map[string]bool // role -> user -> granted
+	userGroups map[string]cachedUserGroup
+	// userGroupMembers looks up the members of a user group on the platform
+	userGroupMembers func(group string) ([]string, error)
+
+	// persistLock orders the writes of grants to the store
+	persistLock sync.Mutex
+
+	store store.Store
+}
+
+type cachedUserGroup struct {
+	members map[string]bool
+	fetched time.Time
+}
+
+func newRBAC(config *RBACConfig, userGroupMembers func(group string) ([]string, error)) *rbac {
+	return &rbac{
+		roles:            config.Roles,
+		grants:           map[string]map[string]bool{},
+		userGroups:       map[string]cachedUserGroup{},
+		userGroupMembers: userGroupMembers,
+	}
+}
+
+// persistTo loads grants from the store and records changes there.
+func (r *rbac) persistTo(st store.Store) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.store = st
+	st.ReadState(func(state *v1.State) {
+		if state == nil || state.RBAC == nil {
+			return
+		}
+		for role, users := range state.RBAC.Grants {
+			for _, user := range users {
+				r.grantLocked(role, user)
+			}
+		}
+	})
+}
+
+// hasRole returns true if the user has the role in the given channel.
+func (r *rbac) hasRole(user, channel, role string) bool {
+	config, ok := r.roles[role]
+	if !ok {
+		return false
+	}
+	if contains(config.Users, user) || contains(config.Channels, channel) {
+		return true
+	}
+
+	r.lock.Lock()
+	granted := r.grants[role][user]
+	r.lock.Unlock()
+	if granted {
+		return true
+	}
+
+	for _, group := range config.UserGroups {
+		if r.inUserGroup(group, user) {
+			return true
+		}
+	}
+	return false
+}
+
+// mayRun returns true if the user has one of the roles the command requires.
+func (r *rbac) mayRun(user, channel string, definition *CommandDefinition) bool {
+	if definition == nil || len(definition.Roles) == 0 {
+		return true
+	}
+	for _, role := range definition.Roles {
+		if r.hasRole(user, channel, role) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *rbac) inUserGroup(group, user string) bool {
+	r.lock.Lock()
+	cached, ok := r.userGroups[group]
+	r.lock.Unlock()
+
+	if !ok || time.Since(cached.fetched) > userGroupCacheTTL {
+		members, err := r.userGroupMembers(group)
+		if err == errNoUserGroups {
+			return false
+		}
+		if err != nil {
+			klog.Warningf("Failed to get members of user group %s: %v", group, err)
+			// stale is better than nothing
+			return cached.members[user]
+		}
+		cached = cachedUserGroup{members: map[string]bool{}, fetched: time.Now()}
+		for _, m := range members {
+			cached.members[m] = true
+		}
+
+		r.lock.Lock()
+		r.userGroups[group] = cached
+		r.lock.Unlock()
+	}
+
+	return cached.members[user]
+}
+
+func (r *rbac) grant(role, user string) error {
+	return r.update(role, func() { r.grantLocked(role, user) })
+}
+
+func (r *rbac) revoke(role, user string) error {
+	return r.update(role, func() { delete(r.grants[role], user) })
+}
+
+func (r *rbac) grantLocked(role, user string) {
+	if r.grants[role] == nil {
+		r.grants[role] = map[string]bool{}
+	}
+	r.grants[role][user] = true
+}
+
+// update changes the grants and persists them, if a store is set.
+func (r *rbac) update(role string, change func()) error {
+	if _, ok := r.roles[role]; !ok {
+		return fmt.Errorf("unknown role %q, must be one of: %s", role, strings.Join(r.roleNames(), ", "))
+	}
+
+	// serialize the writes, without blocking hasRole on the store
+	r.persistLock.Lock()
+	defer r.persistLock.Unlock()
+
+	r.lock.Lock()
+	change()
+	grants := map[string][]string{}
+	for role, users := range r.grants {
+		for user := range users {
+			grants[role] = append(grants[role], user)
+		}
+		sort.Strings(grants[role])
+	}
+	st := r.store
+	r.lock.Unlock()
+
+	if st == nil {
+		return nil
+	}
+	return st.UpdateState(func(old *v1.State) (*v1.State, error) {
+		state := &v1.State{}
+		if old != nil {
+			*state = *old
+		}
+		state.RBAC = &v1.RBAC{Grants: grants}
+		return state, nil
+	})
+}
+
+func (r *rbac) roleNames() []string {
+	names := make([]string, 0, len(r.roles))
+	for name := range r.roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RBAC enables role-based access control for commands with Roles, and registers
+// the grant, revoke and roles commands
+func (s *Slacker) RBAC(config *RBACConfig) {
+	s.rbac = newRBAC(config, s.transport.userGroupMembers)
+	if s.store != nil {
+		s.rbac.persistTo(s.store)
+	}
+	s.addRBACCommands()
+}
+
+// mayRun returns true if the requesting user may run the command according to its roles.
+// Without RBAC nobody has a role, i.e. commands with roles are denied.
+func (s *Slacker) mayRun(user, channel string, definition *CommandDefinition) bool {
+	if s.rbac == nil {
+		return definition == nil || len(definition.Roles) == 0
+	}
+	return s.rbac.mayRun(user, channel, definition)
+}
+
+// mayRunCommand returns true if the requesting user may run the command according to
+// its roles and those of the groups it belongs to.
+func (s *Slacker) mayRunCommand(user, channel string, command BotCommand) bool {
+	return s.mayRun(user, channel, command.Definition()) && commandGroup(command).mayRun(s, user, channel)
+}
+
+// addRBACCommands registers the commands to manage role grants.
+func (s *Slacker) addRBACCommands() {
+	rbacParams := []Param{
+		{Name: "role", Description: "The role.", Required: true},
+		{Name: "user", Description: "The user.", Type: ParamUser, Required: true},
+	}
+	s.Command("grant <role> <user>", &CommandDefinition{
+		Description: "Grant a role to a user.",
+		Example:     "grant bugzilla @alice",
+		Params:      rbacParams,
+		Roles:       []string{AdminRole},
+		Handler: func(request Request, response ResponseWriter) {
+			role, user := request.Param("role"), request.Param("user")
+			if err := s.rbac.grant(role, user); err != nil {
+				response.ReportError(err)
+				return
+			}
+			response.Reply(fmt.Sprintf("Granted role %s to "+userMentionFormat+".", role, user))
+		},
+	})
+	s.Command("revoke <role> <user>", &CommandDefinition{
+		Description: "Revoke a role granted to a user.",
+		Example:     "revoke bugzilla @alice",
+		Params:      rbacParams,
+		Roles:       []string{AdminRole},
+		Handler: func(request Request, response ResponseWriter) {
+			role, user := request.Param("role"), request.Param("user")
+			if err := s.rbac.revoke(role, user); err != nil {
+				response.ReportError(err)
+				return
+			}
+			response.Reply(fmt.Sprintf("Revoked role %s from "+userMentionFormat+". Roles from the configuration stay in place.", role, user))
+		},
+	})
+	s.Command("roles", &CommandDefinition{
+		Description: "Show your roles.",
+		Handler: func(request Request, response ResponseWriter) {
+			var roles []string
+			for _, role := range s.rbac.roleNames() {
+				if s.rbac.hasRole(request.Message().User, request.Message().Channel, role) {
+					roles = append(roles, role)
+				}
+			}
+			if len(roles) == 0 {
+				response.Reply("You have no roles here.")
+				return
+			}
+			response.Reply(fmt.Sprintf("Your roles here: %s", strings.Join(roles, ", ")))
+		},
+	})
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
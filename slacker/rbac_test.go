@@ -0,0 +1,112 @@
+package slacker
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRBAC(t *testing.T) {
+	lookups := 0
+	r := newRBAC(&RBACConfig{Roles: map[string]Role{
+		AdminRole:  {Users: []string{"UADMIN"}},
+		"bugzilla": {UserGroups: []string{"SBZ"}, Channels: []string{"CBZ"}},
+		"broken":   {UserGroups: []string{"SBROKEN"}},
+	}}, func(group string) ([]string, error) {
+		lookups++
+		if group == "SBROKEN" {
+			return nil, errors.New("user group lookup failed")
+		}
+		return []string{"UMEMBER"}, nil
+	})
+
+	tests := []struct {
+		name                string
+		user, channel, role string
+		want                bool
+	}{
+		{"configured user", "UADMIN", "C1", AdminRole, true},
+		{"other user", "UOTHER", "C1", AdminRole, false},
+		{"user group member", "UMEMBER", "C1", "bugzilla", true},
+		{"not a user group member", "UOTHER", "C1", "bugzilla", false},
+		{"anybody in the channel", "UOTHER", "CBZ", "bugzilla", true},
+		{"failing user group lookup", "UMEMBER", "C1", "broken", false},
+		{"unknown role", "UADMIN", "C1", "unknown", false},
+	}
+	for _, tt := range tests {
+		if got := r.hasRole(tt.user, tt.channel, tt.role); got != tt.want {
+			t.Errorf("%s: hasRole(%q, %q, %q) = %v, want %v", tt.name, tt.user, tt.channel, tt.role, got, tt.want)
+		}
+	}
+
+	// user groups are cached
+	before := lookups
+	r.hasRole("UMEMBER", "C1", "bugzilla")
+	if lookups != before {
+		t.Errorf("the user group was looked up again within the cache TTL")
+	}
+
+	if err := r.grant("bugzilla", "UOTHER"); err != nil {
+		t.Fatal(err)
+	}
+	if !r.hasRole("UOTHER", "C1", "bugzilla") {
+		t.Errorf("the granted role is missing")
+	}
+	if err := r.revoke("bugzilla", "UOTHER"); err != nil {
+		t.Fatal(err)
+	}
+	if r.hasRole("UOTHER", "C1", "bugzilla") {
+		t.Errorf("the revoked role is still there")
+	}
+	if err := r.grant("unknown", "UOTHER"); err == nil {
+		t.Errorf("granting an unknown role succeeded")
+	}
+
+	definition := &CommandDefinition{Roles: []string{AdminRole, "bugzilla"}}
+	if !r.mayRun("UMEMBER", "C1", definition) {
+		t.Errorf("a user with one of the roles may not run the command")
+	}
+	if r.mayRun("UOTHER", "C1", definition) {
+		t.Errorf("a user with none of the roles may run the command")
+	}
+	if !r.mayRun("UOTHER", "C1", &CommandDefinition{}) {
+		t.Errorf("a user may not run a command without roles")
+	}
+}
+
+func TestMayRunWithoutRBAC(t *testing.T) {
+	s := NewSlacker(Options{Workers: 1})
+	if !s.mayRun("U1", "C1", &CommandDefinition{}) {
+		t.Errorf("a command without roles is denied")
+	}
+	if s.mayRun("U1", "C1", &CommandDefinition{Roles: []string{AdminRole}}) {
+		t.Errorf("a command with roles is allowed without RBAC")
+	}
+}
+
+func TestLoadRBACConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rbac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	valid := filepath.Join(dir, "valid.yaml")
+	ioutil.WriteFile(valid, []byte("roles:\n  admin:\n    users: [U1]\n    userGroups: [S1]\n"), 0600)
+	config, err := LoadRBACConfig(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Roles[AdminRole]; len(got.Users) != 1 || got.Users[0] != "U1" || len(got.UserGroups) != 1 || got.UserGroups[0] != "S1" {
+		t.Errorf("unexpected admin role %+v", got)
+	}
+
+	// typos must not silently drop a role member
+	typo := filepath.Join(dir, "typo.yaml")
+	ioutil.WriteFile(typo, []byte("roles:\n  admin:\n    user: [U1]\n"), 0600)
+	if _, err := LoadRBACConfig(typo); err == nil {
+		t.Errorf("unknown fields were accepted")
+	}
+}
@@ -0,0 +1,106 @@
+package slacker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	buttonFormat = "[%s]"
+	imageFormat  = "(image %s: %s)"
+	dividerLine  = "----"
+)
+
+var (
+	// mrkdwnLinkPattern matches links like <https://example.com|text>, but not mentions like <@U123>
+	mrkdwnLinkPattern = regexp.MustCompile(`<((?:https?|mailto):[^|>]+)\|([^>]+)>`)
+	// mrkdwnURLPattern matches links without text like <https://example.com>
+	mrkdwnURLPattern    = regexp.MustCompile(`<((?:https?|mailto):[^|>]+)>`)
+	mrkdwnBoldPattern   = regexp.MustCompile(`(^|[\s(_~])\*([^*\n]+)\*`)
+	mrkdwnStrikePattern = regexp.MustCompile(`(^|[\s(_*])~([^~\n]+)~`)
+)
+
+// plainText turns Slack links into text followed by the URL in parentheses, leaving the rest of the mrkdwn as is.
+func plainText(text string) string {
+	return mrkdwnLinkPattern.ReplaceAllString(text, "$2 ($1)")
+}
+
+// markdown converts Slack mrkdwn into the common Markdown dialect of e.g. Mattermost.
+func markdown(text string) string {
+	text = mrkdwnLinkPattern.ReplaceAllString(text, "[$2]($1)")
+	text = mrkdwnURLPattern.ReplaceAllString(text, "$1")
+	text = mrkdwnBoldPattern.ReplaceAllString(text, "$1**$2**")
+	return mrkdwnStrikePattern.ReplaceAllString(text, "$1~~$2~~")
+}
+
+func renderTextObject(text *slack.TextBlockObject, format func(string) string) string {
+	if text == nil {
+		return empty
+	}
+	return format(text.Text)
+}
+
+// renderBlocks renders the blocks as lines of text, for platforms without blocks. Unknown blocks are skipped.
+func renderBlocks(blocks []slack.Block, format func(string) string) []string {
+	var lines []string
+	for _, block := range blocks {
+		switch b := block.(type) {
+		case *slack.SectionBlock:
+			if b.Text != nil {
+				lines = append(lines, renderTextObject(b.Text, format))
+			}
+			for _, field := range b.Fields {
+				lines = append(lines, renderTextObject(field, format))
+			}
+			if b.Accessory != nil && b.Accessory.ButtonElement != nil {
+				lines = append(lines, fmt.Sprintf(buttonFormat, renderTextObject(b.Accessory.ButtonElement.Text, format)))
+			}
+		case *slack.ContextBlock:
+			var texts []string
+			for _, element := range b.ContextElements.Elements {
+				switch e := element.(type) {
+				case *slack.TextBlockObject:
+					texts = append(texts, renderTextObject(e, format))
+				case *slack.ImageBlockElement:
+					texts = append(texts, fmt.Sprintf(imageFormat, e.AltText, e.ImageURL))
+				}
+			}
+			lines = append(lines, strings.Join(texts, space))
+		case *slack.ActionBlock:
+			var buttons []string
+			for _, element := range b.Elements.ElementSet {
+				if button, ok := element.(*slack.ButtonBlockElement); ok {
+					buttons = append(buttons, fmt.Sprintf(buttonFormat, renderTextObject(button.Text, format)))
+				}
+			}
+			lines = append(lines, strings.Join(buttons, space))
+		case *slack.DividerBlock:
+			lines = append(lines, dividerLine)
+		case *slack.ImageBlock:
+			lines = append(lines, fmt.Sprintf(imageFormat, b.AltText, b.ImageURL))
+		}
+	}
+	return lines
+}
+
+// renderAttachments renders the legacy attachments as lines of text.
+func renderAttachments(attachments []slack.Attachment, format func(string) string) []string {
+	var lines []string
+	for _, a := range attachments {
+		for _, text := range []string{a.Pretext, a.Title, a.TitleLink, a.Text} {
+			if len(text) > 0 {
+				lines = append(lines, format(text))
+			}
+		}
+		for _, field := range a.Fields {
+			lines = append(lines, format(field.Title+": "+field.Value))
+		}
+		if len(a.Footer) > 0 {
+			lines = append(lines, format(a.Footer))
+		}
+	}
+	return lines
+}
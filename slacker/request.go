@@ -2,18 +2,33 @@ package slacker
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/shomali11/proper"
-	"github.com/slack-go/slack/slackevents"
 )
 
 const (
 	empty = ""
 )
 
-// NewRequest creates a new Request structure
-func NewRequest(ctx context.Context, event *slackevents.MessageEvent, properties *proper.Properties) Request {
-	return &request{ctx: ctx, event: event, properties: properties}
+// NewMessageRequest creates a new Request structure for a message of any platform
+func NewMessageRequest(ctx context.Context, message *Message, properties *proper.Properties) Request {
+	return &request{ctx: ctx, message: message, properties: properties}
+}
+
+func newCommandRequest(ctx context.Context, message *Message, properties *proper.Properties, command BotCommand, values map[string][]string) Request {
+	return &request{ctx: ctx, message: message, properties: properties, command: command, values: values}
+}
+
+// withContext returns a copy of the request with another context.
+func withContext(r Request, ctx context.Context) Request {
+	if r, ok := r.(*request); ok {
+		copy := *r
+		copy.ctx = ctx
+		return &copy
+	}
+	return newCommandRequest(ctx, r.Message(), r.Properties(), r.Command(), nil)
 }
 
 // Request interface that contains the Event received and parameters
@@ -23,16 +38,23 @@ type Request interface {
 	BooleanParam(key string, defaultValue bool) bool
 	IntegerParam(key string, defaultValue int) int
 	FloatParam(key string, defaultValue float64) float64
+	DurationParam(key string, defaultValue time.Duration) time.Duration
+	DateParam(key string, defaultValue time.Time) time.Time
+	ListParam(key string) []string
+	Values(key string) []string
 	Context() context.Context
-	Event() *slackevents.MessageEvent
+	Message() *Message
 	Properties() *proper.Properties
+	Command() BotCommand
 }
 
 // request contains the Event received and parameters
 type request struct {
 	ctx        context.Context
-	event      *slackevents.MessageEvent
+	message    *Message
 	properties *proper.Properties
+	command    BotCommand
+	values     map[string][]string
 }
 
 // Param attempts to look up a string value by key. If not found, return the an empty string
@@ -60,17 +82,55 @@ func (r *request) FloatParam(key string, defaultValue float64) float64 {
 	return r.properties.FloatParam(key, defaultValue)
 }
 
+// DurationParam attempts to look up a duration value by key, see ParamDuration. If not found, return the default duration value
+func (r *request) DurationParam(key string, defaultValue time.Duration) time.Duration {
+	if d, ok := parseDuration(r.properties.StringParam(key, empty)); ok {
+		return d
+	}
+	return defaultValue
+}
+
+// DateParam attempts to look up a date value by key, see ParamDate. If not found, return the default date value
+func (r *request) DateParam(key string, defaultValue time.Time) time.Time {
+	if date, ok := parseDate(r.properties.StringParam(key, empty), time.Now()); ok {
+		return date
+	}
+	return defaultValue
+}
+
+// ListParam attempts to look up a comma separated list by key, see ParamList, or all values of a flag. If not found, return nil
+func (r *request) ListParam(key string) []string {
+	if values, ok := r.values[key]; ok {
+		return values
+	}
+	value := strings.Trim(r.properties.StringParam(key, empty), ", ")
+	if len(value) == 0 {
+		return nil
+	}
+	return listSeparators.Split(value, -1)
+}
+
+// Values returns all values of a flag, see CommandDefinition.Flags, in the order given. If not found, return nil
+func (r *request) Values(key string) []string {
+	return r.values[key]
+}
+
 // Context returns the current context of the request
 func (r *request) Context() context.Context {
 	return r.ctx
 }
 
-// Event returns the current event of the request
-func (r *request) Event() *slackevents.MessageEvent {
-	return r.event
+// Message returns the message of the request, independent of the platform
+func (r *request) Message() *Message {
+	return r.message
 }
 
 // Properties returns the properties of the request
 func (r *request) Properties() *proper.Properties {
 	return r.properties
 }
+
+// Command returns the matched command, or nil for the default handler
+func (r *request) Command() BotCommand {
+	return r.command
+}
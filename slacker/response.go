@@ -1,7 +1,10 @@
 package slacker
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
@@ -11,63 +14,213 @@ const (
 	errorFormat = "*Error:* _%s_"
 )
 
-// A ResponseWriter interface is used to respond to an event
+var errNoMessage = errors.New("there is no message to react to")
+
+// A ResponseWriter interface is used to respond to an event. The methods posting
+// a message return its timestamp, which is empty for replies through a response_url.
+// On other platforms than Slack, the timestamp is the ID of the message.
 type ResponseWriter interface {
-	Reply(text string, options ...ReplyOption) error
+	Reply(text string, options ...ReplyOption) (string, error)
+	ReplyEphemeral(text string, options ...ReplyOption) (string, error)
+	Update(timestamp string, text string, options ...ReplyOption) (string, error)
+	Delete(timestamp string) error
+	React(emoji string) error
+	UploadFile(name string, content io.Reader) (string, error)
+	DM(user string, text string, options ...ReplyOption) (string, error)
+	Progress() Progress
 	ReportError(err error, options ...ReportErrorOption)
-	Client() *slack.Client
 }
 
 // NewResponse creates a new response structure
 func NewResponse(event *slackevents.MessageEvent, client *slack.Client) ResponseWriter {
-	return &response{event: event, client: client}
+	return &response{
+		messenger: messenger{client: client, channel: event.Channel, user: event.User, timestamp: event.TimeStamp, threadTimestamp: event.ThreadTimeStamp},
+		event:     event,
+	}
 }
 
 type response struct {
-	event  *slackevents.MessageEvent
-	client *slack.Client
+	messenger
+	event *slackevents.MessageEvent
 }
 
-// ReportError sends back a formatted error message to the channel where we received the event from
+// ReportError sends back a formatted error message to the channel where we received the event from,
+// into the thread following the same rules as Reply
 func (r *response) ReportError(err error, options ...ReportErrorOption) {
-	defaults := newReportErrorDefaults(options...)
+	defaults := newReportErrorDefaults(append([]ReportErrorOption{WithThreadError(r.threadReplies)}, options...)...)
 
 	opts := []slack.MsgOption{
 		slack.MsgOptionText(fmt.Sprintf(errorFormat, err.Error()), false),
+		slack.MsgOptionAsUser(true),
 	}
-	if defaults.ThreadResponse {
-		opts = append(opts, slack.MsgOptionTS(r.event.ThreadTimeStamp))
+	r.client.SendMessage(r.channel, append(opts, r.threadOptions(defaults.ThreadResponse, false)...)...)
+}
+
+// Reply sends a message to the channel of the event. It goes into the thread if
+// the event is part of one, or if WithThreadReply or the ThreadReplies default
+// of the command ask for it
+func (r *response) Reply(message string, options ...ReplyOption) (string, error) {
+	defaults := r.replyDefaults(options...)
+
+	opts := []slack.MsgOption{
+		slack.MsgOptionText(message, false),
+		slack.MsgOptionAsUser(true),
+		slack.MsgOptionAttachments(defaults.Attachments...),
+		slack.MsgOptionBlocks(defaults.Blocks...),
 	}
-	r.client.SendMessage(r.event.Channel, opts...)
+	_, timestamp, err := r.client.PostMessage(r.channel, append(opts, r.threadOptions(defaults.ThreadResponse, defaults.Broadcast)...)...)
+	return timestamp, err
 }
 
-// Reply send a attachments to the current channel with a message
-func (r *response) Reply(message string, options ...ReplyOption) error {
-	defaults := newReplyDefaults(options...)
+// Progress returns a status message handle for long-running commands
+func (r *response) Progress() Progress {
+	return newProgress(r)
+}
+
+// messenger implements the ResponseWriter methods which work the same for
+// every kind of response, given the channel, the user and the triggering message.
+type messenger struct {
+	client          *slack.Client
+	channel         string
+	user            string
+	timestamp       string
+	threadTimestamp string
+
+	// threadReplies is the default of WithThreadReply, from the command definition
+	threadReplies bool
+}
 
-	if defaults.ThreadResponse {
-		_, _, err := r.client.PostMessage(
-			r.event.Channel,
-			slack.MsgOptionText(message, false),
-			slack.MsgOptionAsUser(true),
-			slack.MsgOptionAttachments(defaults.Attachments...),
-			slack.MsgOptionBlocks(defaults.Blocks...),
-			slack.MsgOptionTS(r.event.ThreadTimeStamp), // TODO: is this EventTimeStamp?
-		)
-		return err
+// threadReplier is implemented by responses which can reply into threads.
+type threadReplier interface {
+	setThreadReplies(threadReplies bool)
+}
+
+// setThreadReplies sets the default for replies into the thread.
+func (m *messenger) setThreadReplies(threadReplies bool) {
+	m.threadReplies = threadReplies
+}
+
+func (m *messenger) replyDefaults(options ...ReplyOption) *ReplyDefaults {
+	return newReplyDefaults(append([]ReplyOption{WithThreadReply(m.threadReplies)}, options...)...)
+}
+
+// threadTS returns the thread to reply into, i.e. the thread of the triggering
+// message, or the message itself if it started no thread yet.
+func (m *messenger) threadTS(thread bool) string {
+	if len(m.threadTimestamp) > 0 {
+		return m.threadTimestamp
+	}
+	if thread {
+		return m.timestamp
+	}
+	return empty
+}
+
+func (m *messenger) threadOptions(thread, broadcast bool) []slack.MsgOption {
+	ts := m.threadTS(thread)
+	if len(ts) == 0 {
+		return nil
 	}
+	opts := []slack.MsgOption{slack.MsgOptionTS(ts)}
+	if broadcast {
+		opts = append(opts, slack.MsgOptionBroadcast())
+	}
+	return opts
+}
 
-	_, _, err := r.client.PostMessage(
-		r.event.Channel,
+// ReplyEphemeral sends a message to the channel which only the user sees, into the thread like Reply
+func (m *messenger) ReplyEphemeral(message string, options ...ReplyOption) (string, error) {
+	defaults := m.replyDefaults(options...)
+
+	opts := []slack.MsgOption{
 		slack.MsgOptionText(message, false),
 		slack.MsgOptionAsUser(true),
 		slack.MsgOptionAttachments(defaults.Attachments...),
 		slack.MsgOptionBlocks(defaults.Blocks...),
+	}
+	return m.client.PostEphemeral(m.channel, m.user, append(opts, m.threadOptions(defaults.ThreadResponse, false)...)...)
+}
+
+// Update replaces the text of a message sent to the channel before. Ephemeral messages cannot be updated
+func (m *messenger) Update(timestamp string, message string, options ...ReplyOption) (string, error) {
+	if len(timestamp) == 0 {
+		return empty, errors.New("there is no message to update")
+	}
+	defaults := newReplyDefaults(options...)
+
+	_, timestamp, _, err := m.client.UpdateMessage(m.channel, timestamp,
+		slack.MsgOptionText(message, false),
+		slack.MsgOptionAttachments(defaults.Attachments...),
+		slack.MsgOptionBlocks(defaults.Blocks...),
 	)
+	return timestamp, err
+}
+
+// Delete removes a message sent to the channel before
+func (m *messenger) Delete(timestamp string) error {
+	_, _, err := m.client.DeleteMessage(m.channel, timestamp)
 	return err
 }
 
+// React adds an emoji reaction, e.g. "eyes", to the message which triggered the command
+func (m *messenger) React(emoji string) error {
+	if len(m.timestamp) == 0 {
+		return errNoMessage
+	}
+	return m.client.AddReaction(strings.Trim(emoji, ":"), slack.NewRefToMessage(m.channel, m.timestamp))
+}
+
+// UploadFile shares a file in the channel, into the thread like Reply, and returns
+// the timestamp of the message showing it, if Slack returns it
+func (m *messenger) UploadFile(name string, content io.Reader) (string, error) {
+	file, err := m.client.UploadFile(slack.FileUploadParameters{
+		Reader:          content,
+		Filename:        name,
+		Title:           name,
+		Channels:        []string{m.channel},
+		ThreadTimestamp: m.threadTS(m.threadReplies),
+	})
+	if err != nil {
+		return empty, err
+	}
+	for _, shares := range []map[string][]slack.ShareFileInfo{file.Shares.Public, file.Shares.Private} {
+		if infos := shares[m.channel]; len(infos) > 0 {
+			return infos[0].Ts, nil
+		}
+	}
+	return empty, nil
+}
+
+// unreact removes an emoji reaction of the bot from the message which triggered the command
+func (m *messenger) unreact(emoji string) error {
+	if len(m.timestamp) == 0 {
+		return errNoMessage
+	}
+	err := m.client.RemoveReaction(strings.Trim(emoji, ":"), slack.NewRefToMessage(m.channel, m.timestamp))
+	if err != nil && strings.Contains(err.Error(), "no_reaction") {
+		return nil
+	}
+	return err
+}
+
+// DM sends a direct message to the user. Its timestamp refers to the DM channel, not to the one of the response
+func (m *messenger) DM(user string, message string, options ...ReplyOption) (string, error) {
+	defaults := newReplyDefaults(options...)
+
+	channel, _, _, err := m.client.OpenConversation(&slack.OpenConversationParameters{Users: []string{user}})
+	if err != nil {
+		return empty, err
+	}
+	_, timestamp, err := m.client.PostMessage(channel.ID,
+		slack.MsgOptionText(message, false),
+		slack.MsgOptionAsUser(true),
+		slack.MsgOptionAttachments(defaults.Attachments...),
+		slack.MsgOptionBlocks(defaults.Blocks...),
+	)
+	return timestamp, err
+}
+
 // Client returns the slack client
-func (r *response) Client() *slack.Client {
-	return r.client
+func (m *messenger) Client() *slack.Client {
+	return m.client
 }
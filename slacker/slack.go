@@ -0,0 +1,279 @@
+package slacker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/handlers"
+	"github.com/shomali11/proper"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"k8s.io/klog"
+)
+
+const (
+	// EventsTransport receives Slack events through the HTTP Events API endpoint.
+	EventsTransport = "events"
+	// SocketModeTransport receives Slack events over an outbound Socket Mode websocket.
+	SocketModeTransport = "socket"
+)
+
+// SlackResponseWriter is implemented by the ResponseWriters replying in Slack. It gives
+// access to the Slack API for what the platform-neutral ResponseWriter does not cover,
+// see SlackClient.
+type SlackResponseWriter interface {
+	ResponseWriter
+	Client() *slack.Client
+}
+
+// unwrapper is implemented by ResponseWriters wrapping another one.
+type unwrapper interface {
+	unwrap() ResponseWriter
+}
+
+// SlackClient returns the Slack client of a response, or nil if it does not reply in Slack.
+func SlackClient(response ResponseWriter) *slack.Client {
+	for {
+		switch r := response.(type) {
+		case SlackResponseWriter:
+			return r.Client()
+		case unwrapper:
+			response = r.unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// SlackEvent returns the Slack event the message of the request was received as,
+// or nil if it was not received from Slack as message event.
+func SlackEvent(request Request) *slackevents.MessageEvent {
+	event, _ := request.Message().original.(*slackevents.MessageEvent)
+	return event
+}
+
+// NewRequest creates a new Request structure for a Slack message event
+func NewRequest(ctx context.Context, event *slackevents.MessageEvent, properties *proper.Properties) Request {
+	return NewMessageRequest(ctx, NewSlackMessage(event), properties)
+}
+
+// NewSlackMessage converts a Slack message event into a Message
+func NewSlackMessage(event *slackevents.MessageEvent) *Message {
+	return &Message{
+		Platform: SlackPlatform,
+		ID:       event.TimeStamp,
+		User:     event.User,
+		Channel:  event.Channel,
+		Thread:   event.ThreadTimeStamp,
+		Text:     event.Text,
+		Direct:   event.ChannelType == "im" || strings.HasPrefix(event.Channel, directChannelMarker),
+		original: event,
+	}
+}
+
+// slackTransport receives Slack events through the Events API or Socket Mode.
+type slackTransport struct {
+	s          *Slacker
+	socketMode bool
+	verifier   requestVerifier
+
+	// client is created by connect
+	client *slack.Client
+}
+
+func newSlackTransport(s *Slacker, opt Options) *slackTransport {
+	return &slackTransport{s: s, socketMode: opt.Transport == SocketModeTransport, verifier: newRequestVerifier(opt)}
+}
+
+// connect creates the Slack client and identifies the bot user.
+func (t *slackTransport) connect(ctx context.Context) (string, error) {
+	t.client = t.s.newClient()
+	auth, err := t.client.AuthTestContext(ctx)
+	if err != nil {
+		return empty, err
+	}
+	klog.Infof("Running as bot user %s (%s)", auth.User, auth.UserID)
+	return auth.UserID, nil
+}
+
+func (t *slackTransport) listen(ctx context.Context) error {
+	if t.socketMode {
+		return t.s.listenSocketMode(ctx, t.client)
+	}
+	klog.Infof("sttts-bot up and listening to slack on %s", t.s.listenAddress)
+	return t.s.serve(ctx, handlers.LoggingHandler(os.Stdout, t.handler()))
+}
+
+// userGroupMembers returns the members of the Slack user group.
+func (t *slackTransport) userGroupMembers(group string) ([]string, error) {
+	if t.client == nil {
+		// not connected, e.g. in the terminal
+		return nil, errNoUserGroups
+	}
+	return t.client.GetUserGroupMembers(group)
+}
+
+// handler serves the /events, /commands and /interactions endpoints.
+func (t *slackTransport) handler() http.Handler {
+	s, client := t.s, t.client
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", verified(t.verifier, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		eventsAPIEvent, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
+		if err != nil {
+			klog.Warningf("Failed to parse event: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if eventsAPIEvent.Type == slackevents.URLVerification {
+			var r *slackevents.ChallengeResponse
+			err := json.Unmarshal(body, &r)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "text")
+			w.Write([]byte(r.Challenge))
+			return
+		}
+
+		retry, _ := strconv.Atoi(r.Header.Get(retryNumHeader))
+		s.handleEventsAPIEvent(client, eventsAPIEvent, retry)
+	}))
+	mux.HandleFunc("/commands", verified(t.verifier, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		command, err := slack.SlashCommandParse(r)
+		if err != nil {
+			klog.Warningf("Failed to parse slash command: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// ack immediately, the answer goes to the response_url
+		w.WriteHeader(http.StatusOK)
+		s.handleSlashCommand(client, &command)
+	}))
+	mux.HandleFunc("/interactions", verified(t.verifier, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		var callback slack.InteractionCallback
+		if err := json.Unmarshal([]byte(r.PostFormValue("payload")), &callback); err != nil {
+			klog.Warningf("Failed to parse interaction payload: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// ack immediately, this also closes submitted views
+		w.WriteHeader(http.StatusOK)
+		s.handleInteraction(client, &callback)
+	}))
+
+	return mux
+}
+
+// handleEventsAPIEvent dispatches a verified Events API callback, independent
+// of the transport it was received on. Retried deliveries are dropped if they
+// were seen before, or always with IgnoreRetries.
+func (s *Slacker) handleEventsAPIEvent(client *slack.Client, eventsAPIEvent slackevents.EventsAPIEvent, retry int) {
+	if eventsAPIEvent.Type != slackevents.CallbackEvent {
+		return
+	}
+
+	if retry > 0 && s.ignoreRetries {
+		klog.V(2).Infof("Dropping retry %d of event", retry)
+		metrics.Add(metricEventsRetryDropped, 1)
+		return
+	}
+	var eventID string
+	if callback, ok := eventsAPIEvent.Data.(*slackevents.EventsAPICallbackEvent); ok {
+		eventID = callback.EventID
+	}
+
+	innerEvent := eventsAPIEvent.InnerEvent
+	klog.Infof("CallbackEvent: %s", innerEvent.Type)
+
+	// only events addressed to the bot are deduplicated, all others are dropped anyway
+	switch ev := innerEvent.Data.(type) {
+	case *slackevents.AppMentionEvent:
+		if s.duplicate(eventID, retry) {
+			break
+		}
+		message := s.mentionMessage(ev)
+		s.participate(message)
+		s.handleMessage(client, message)
+	case *slackevents.MessageEvent:
+		if !s.addressed(ev) || s.duplicate(eventID, retry) {
+			break
+		}
+		s.participate(ev)
+		s.handleMessage(client, ev)
+	}
+}
+
+// duplicate returns true if the event was seen before.
+func (s *Slacker) duplicate(eventID string, retry int) bool {
+	if s.dedup == nil || !s.dedup.Seen(eventID) {
+		return false
+	}
+	klog.Infof("Dropping duplicate event %s (retry %d)", eventID, retry)
+	metrics.Add(metricEventsDuplicateDropped, 1)
+	return true
+}
+
+// handleMessage queues the message for the workers.
+func (s *Slacker) handleMessage(client *slack.Client, event *slackevents.MessageEvent) {
+	response := NewResponse(event, client)
+	message := NewSlackMessage(event)
+	s.submitCommand(message.Text, response, func(ctx context.Context, response *replyRecorder) { s.dispatch(ctx, message, response) })
+}
+
+// handleSlashCommand runs a slash command like "/bz stats" through the registered
+// commands. The text is matched first on its own, and then prefixed with the
+// command name, i.e. "stats" and then "bz stats".
+func (s *Slacker) handleSlashCommand(client *slack.Client, command *slack.SlashCommand) {
+	text := strings.TrimSpace(command.Text)
+	if s.matchCommand(text) == nil {
+		text = strings.TrimSpace(strings.TrimPrefix(command.Command, slashCommandPrefix) + space + text)
+	}
+
+	// fake message event
+	message := NewSlackMessage(&slackevents.MessageEvent{
+		Type:    slashCommandEventType,
+		User:    command.UserID,
+		Text:    text,
+		Channel: command.ChannelID,
+	})
+	response := NewSlashCommandResponse(command, client)
+	s.submitCommand(text, response, func(ctx context.Context, response *replyRecorder) { s.dispatch(ctx, message, response) })
+}
+
+// handleInteraction runs the registered action handlers for block actions and view submissions.
+func (s *Slacker) handleInteraction(client *slack.Client, callback *slack.InteractionCallback) {
+	switch callback.Type {
+	case slack.InteractionTypeBlockActions:
+		for _, action := range callback.ActionCallback.BlockActions {
+			s.runAction(client, action.ActionID, callback, action)
+		}
+	case slack.InteractionTypeViewSubmission:
+		s.runAction(client, callback.View.CallbackID, callback, nil)
+	default:
+		klog.V(2).Infof("Ignoring interaction of type %q", callback.Type)
+	}
+}
+
+func (s *Slacker) runAction(client *slack.Client, actionID string, callback *slack.InteractionCallback, action *slack.BlockAction) {
+	handler, ok := s.actionHandlers[actionID]
+	if !ok {
+		klog.Warningf("No handler registered for action %q", actionID)
+		return
+	}
+	response := NewActionResponse(callback, client)
+	s.submit(response, func(ctx context.Context, recorder *replyRecorder) {
+		// run through the middlewares like commands, e.g. to recover panics
+		actionRequest := NewActionRequest(ctx, callback, action)
+		s.chain(func(Request, ResponseWriter) {
+			handler(actionRequest, &actionReplyRecorder{replyRecorder: recorder, original: response})
+		})(NewMessageRequest(ctx, actionMessage(callback, action), &proper.Properties{}), recorder)
+	})
+}
@@ -1,19 +1,19 @@
 package slacker
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/gorilla/handlers"
+	"github.com/gorilla/websocket"
 	"github.com/shomali11/proper"
-	"github.com/slack-go/slack"
-	"github.com/slack-go/slack/slackevents"
 	"k8s.io/klog"
+
+	"github.com/sttts/sttts-bot/store"
 )
 
 const (
@@ -31,23 +31,118 @@ const (
 	quoteMessageFormat  = ">_*Example:* %s_"
 	authorizedUsersOnly = "Authorized users only"
 	slackBotUser        = "USLACKBOT"
+
+	retryNumHeader = "X-Slack-Retry-Num"
+
+	drainGracePeriod  = 5 * time.Second
+	restartingMessage = "Sorry, the bot is restarting and could not finish your command. Please try again in a minute."
+
+	// statePersistInterval is how often changed state like seen event IDs and rate limits is written to the store
+	statePersistInterval = 10 * time.Second
+
+	slashCommandPrefix    = "/"
+	slashCommandEventType = "slash_command"
 )
 
+var errNotAuthorized = errors.New("You are not authorized to execute this command")
+
 type Slacker struct {
-	token             string
-	listenAddress     string
-	verificationToken string
+	token          string
+	appToken       string
+	listenAddress  string
+	metricsAddress string
+
+	clientDefaults  *ClientDefaults
+	websocketDialer *websocket.Dialer
+	transport       transport
+
+	dedup         *eventDeduplicator
+	dispatcher    *dispatcher
+	rateLimiter   *rateLimiter
+	rbac          *rbac
+	channels      *channelConfig
+	jobs          *jobRegistry
+	store         store.Store
+	addressing    AddressingPolicy
+	threads       *threadTracker
+	botUserID     string
+	ignoreRetries bool
+	drainTimeout  time.Duration
+
+	commandTimeoutDefault time.Duration
+
+	// handlerCtx is passed to the handlers. It outlives Listen's context and is
+	// only cancelled when running handlers did not finish within the drain timeout.
+	handlerCtx     context.Context
+	cancelHandlers context.CancelFunc
+	running        sync.WaitGroup
 
 	botCommands           []BotCommand
+	groups                []*CommandGroup
+	actionHandlers        map[string]func(request ActionRequest, response ActionResponseWriter)
 	helpDefinition        *CommandDefinition
+	helpPrepended         bool
 	defaultMessageHandler func(request Request, response ResponseWriter)
+	middlewares           []Middleware
+}
+
+// NewSlacker creates the bot. The client options override the ones derived from the Options.
+func NewSlacker(opt Options, options ...ClientOption) *Slacker {
+	s := &Slacker{
+		token:          opt.Token,
+		appToken:       opt.AppToken,
+		listenAddress:  opt.ListenAddress,
+		metricsAddress: opt.MetricsAddress,
+		ignoreRetries:  opt.IgnoreRetries,
+		dispatcher:     newDispatcher(opt.Workers, opt.QueueSize),
+		drainTimeout:   opt.DrainTimeout,
+		addressing: AddressingPolicy{
+			RequireMentionInChannels: opt.RequireMentionInChannels,
+			RequireMentionInDMs:      opt.RequireMentionInDMs,
+			RequireMentionInThreads:  opt.RequireMentionInThreads,
+		},
+		threads:  newThreadTracker(),
+		channels: newChannelConfig(opt.ChannelAllowlist),
+		jobs:     newJobRegistry(),
+
+		commandTimeoutDefault: opt.CommandTimeout,
+	}
+	s.handlerCtx, s.cancelHandlers = context.WithCancel(context.Background())
+	clientOptions := []ClientOption{WithDebug(opt.Debug), WithHTTPClient(newHTTPClient(opt))}
+	if len(opt.APIURL) > 0 {
+		clientOptions = append(clientOptions, WithAPIURL(opt.APIURL))
+	}
+	s.clientDefaults = newClientDefaults(append(clientOptions, options...)...)
+	s.websocketDialer = newWebsocketDialer(opt)
+	switch opt.Platform {
+	case MattermostPlatform:
+		s.transport = newMattermostTransport(s, opt)
+	default:
+		s.transport = newSlackTransport(s, opt)
+	}
+	if opt.EventDedupTTL > 0 {
+		s.dedup = newEventDeduplicator(opt.EventDedupTTL, opt.EventDedupSize)
+	}
+	// validated in ValidateOptions
+	userLimit, _ := ParseRateLimit(opt.UserRateLimit)
+	channelLimit, _ := ParseRateLimit(opt.ChannelRateLimit)
+	s.rateLimiter = newRateLimiter(userLimit, channelLimit)
+	s.Action(runSuggestionActionID, s.runSuggestion)
+	s.addChannelCommands()
+	s.addJobCommands()
+	return s
 }
 
-func NewSlacker(opt Options) *Slacker {
-	return &Slacker{
-		token:             opt.Token,
-		listenAddress:     opt.ListenAddress,
-		verificationToken: opt.VerificationToken,
+// StateStore persists state like seen event IDs and rate limits in the given store
+func (s *Slacker) StateStore(st store.Store) {
+	s.store = st
+	if s.dedup != nil {
+		s.dedup.persistTo(st)
+	}
+	s.rateLimiter.persistTo(st)
+	s.channels.persistTo(st)
+	if s.rbac != nil {
+		s.rbac.persistTo(st)
 	}
 }
 
@@ -58,7 +153,32 @@ func (s *Slacker) Help(definition *CommandDefinition) {
 
 // Command define a new command and append it to the list of existing commands
 func (s *Slacker) Command(usage string, definition *CommandDefinition) {
-	s.botCommands = append(s.botCommands, NewBotCommand(usage, definition))
+	s.command(usage, definition, nil)
+}
+
+func (s *Slacker) command(usage string, definition *CommandDefinition, group *CommandGroup) {
+	command := NewBotCommand(usage, definition)
+	command.(*botCommand).group = group
+	s.botCommands = append(s.botCommands, command)
+	if definition != nil && definition.MaxConcurrency > 0 {
+		s.dispatcher.limit(usage, definition.MaxConcurrency)
+	}
+}
+
+// Use appends middlewares wrapping every command and the default handler. The
+// first middleware is the outermost one. Commands are authorized before any
+// middleware runs, and the middlewares of command groups run inside of these.
+func (s *Slacker) Use(middlewares ...Middleware) {
+	s.middlewares = append(s.middlewares, middlewares...)
+}
+
+// Action registers a handler for interactive components. The handler is called for
+// block actions with the given action_id, and for view submissions with the given callback_id
+func (s *Slacker) Action(actionID string, handler func(request ActionRequest, response ActionResponseWriter)) {
+	if s.actionHandlers == nil {
+		s.actionHandlers = map[string]func(request ActionRequest, response ActionResponseWriter){}
+	}
+	s.actionHandlers[actionID] = handler
 }
 
 // DefaultCommand handle messages when none of the commands are matched
@@ -66,147 +186,237 @@ func (s *Slacker) DefaultCommand(defaultMessageHandler func(request Request, res
 	s.defaultMessageHandler = defaultMessageHandler
 }
 
+// Listen receives events until the context is done. Then it stops accepting new
+// events and drains the running and queued commands before returning.
 func (s *Slacker) Listen(ctx context.Context) error {
-	client := slack.New(s.token, slack.OptionDebug(true))
-	s.prependHelpHandle()
+	defer s.persistState()()
+	defer s.dispatcher.start()()
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
-		buf := new(bytes.Buffer)
-		buf.ReadFrom(r.Body)
-		body := buf.String()
-		eventsAPIEvent, e := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionVerifyToken(&slackevents.TokenComparator{VerificationToken: s.verificationToken}))
-		if e != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-		}
+	if err := s.connect(ctx); err != nil {
+		return err
+	}
+	err := s.transport.listen(ctx)
+	if ctx.Err() != nil {
+		s.drain()
+		return nil
+	}
+	return err
+}
 
-		switch eventsAPIEvent.Type {
-		case slackevents.URLVerification:
-			var r *slackevents.ChallengeResponse
-			err := json.Unmarshal([]byte(body), &r)
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-			}
-			w.Header().Set("Content-Type", "text")
-			w.Write([]byte(r.Challenge))
-
-		case slackevents.CallbackEvent:
-			var _ CommandDefinition
-			innerEvent := eventsAPIEvent.InnerEvent
-			klog.Infof("CallbackEvent: %s", innerEvent.Type)
-
-			if ev, ok := innerEvent.Data.(*slackevents.AppMentionEvent); ok {
-				// fake message event
-				innerEvent = slackevents.EventsAPIInnerEvent{
-					Type: ev.Type,
-					Data: &slackevents.MessageEvent{
-						Type:            ev.Type,
-						User:            ev.User,
-						Text:            ev.Text,
-						TimeStamp:       ev.TimeStamp,
-						ThreadTimeStamp: ev.ThreadTimeStamp,
-						Channel:         ev.Channel,
-						EventTimeStamp:  ev.EventTimeStamp,
-						UserTeam:        ev.UserTeam,
-						SourceTeam:      ev.SourceTeam,
-					},
-				}
-			}
+// Handler returns the HTTP handler serving the endpoints of the platform, i.e. /events,
+// /commands and /interactions for Slack and /mattermost for Mattermost, e.g. to embed
+// the bot into another server or to test it with httptest.
+// The commands run until the context is done, then they are drained like in Listen.
+func (s *Slacker) Handler(ctx context.Context) (http.Handler, error) {
+	if err := s.connect(ctx); err != nil {
+		return nil, err
+	}
+	stop := s.dispatcher.start()
+	go func() {
+		<-ctx.Done()
+		s.drain()
+		stop()
+	}()
+	return s.transport.handler(), nil
+}
 
-			switch ev := innerEvent.Data.(type) {
-			case *slackevents.MessageEvent:
-				// ignore my own messages
-				if len(ev.BotID) > 0 {
-					break
-				}
+// connect identifies the bot user on the platform.
+func (s *Slacker) connect(ctx context.Context) error {
+	s.prependHelpHandle()
 
-				go s.handleMessage(ctx, client, ev)
-			}
-		}
-	})
+	if len(s.botUserID) > 0 {
+		return nil
+	}
+	botUserID, err := s.transport.connect(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to identify the bot user: %v", err)
+	}
+	s.botUserID = botUserID
+	return nil
+}
 
-	klog.Infof("sttts-bot up and listening to slack on %s", s.listenAddress)
-	server := &http.Server{Addr: s.listenAddress, Handler: handlers.LoggingHandler(os.Stdout, mux)}
+// serve runs the HTTP server on the listen address until the context is done.
+func (s *Slacker) serve(ctx context.Context, handler http.Handler) error {
+	server := &http.Server{Addr: s.listenAddress, Handler: handler}
+	shutdown := make(chan struct{})
 	go func() {
+		defer close(shutdown)
 		<-ctx.Done()
 		klog.Infof("Shutting down")
-		server.Close()
-	}()
-	return server.ListenAndServe()
-}
-
-func (s *Slacker) handleMessage(ctx context.Context, client *slack.Client, message *slackevents.MessageEvent) {
-	response := NewResponse(message, client)
 
-	for _, cmd := range s.botCommands {
-		parameters, isMatch := cmd.Match(message.Text)
-		if !isMatch {
-			continue
+		// in-flight requests only enqueue work, they finish quickly
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.drainTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			klog.Warningf("Failed to shut down HTTP server gracefully: %v", err)
+			server.Close()
 		}
+	}()
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	<-shutdown
+	return nil
+}
 
-		request := NewRequest(ctx, message, parameters)
-		if cmd.Definition().AuthorizationFunc != nil && !cmd.Definition().AuthorizationFunc(request) {
-			response.ReportError(errors.New("You are not authorized to execute this command"))
-			return
-		}
+// drain waits for running and queued handlers. After the drain timeout their
+// context is cancelled, and they get a grace period to reply that the bot is restarting.
+func (s *Slacker) drain() {
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
 
-		cmd.Execute(request, response)
+	klog.Infof("Waiting up to %v for running commands", s.drainTimeout)
+	select {
+	case <-done:
 		return
+	case <-time.After(s.drainTimeout):
 	}
 
-	if s.defaultMessageHandler != nil {
-		request := NewRequest(ctx, message, &proper.Properties{})
-		s.defaultMessageHandler(request, response)
+	klog.Warningf("Running commands did not finish within %v, cancelling them", s.drainTimeout)
+	s.cancelHandlers()
+	select {
+	case <-done:
+	case <-time.After(drainGracePeriod):
+		klog.Warningf("Running commands did not return within %v after cancellation", drainGracePeriod)
 	}
 }
 
-func (s *Slacker) prependHelpHandle() {
-	if s.helpDefinition == nil {
-		s.helpDefinition = &CommandDefinition{}
+// persistState flushes the state changed by incoming events to the store
+// periodically, on a single goroutine so that writes cannot overtake each other.
+// The returned func stops it after a last flush.
+func (s *Slacker) persistState() func() {
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(statePersistInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				s.flushState()
+				return
+			case <-ticker.C:
+				s.flushState()
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
 	}
+}
 
-	if s.helpDefinition.Handler == nil {
-		s.helpDefinition.Handler = s.defaultHelp
+func (s *Slacker) flushState() {
+	if s.dedup != nil {
+		s.dedup.flush()
 	}
+	s.rateLimiter.flush()
+}
 
-	if len(s.helpDefinition.Description) == 0 {
-		s.helpDefinition.Description = helpCommand
+// matchCommand returns the most specific command matching the text from its beginning, or nil.
+// The most specific command is the one with the most words in its name, e.g. "bz show <id>"
+// wins over "bz <query>", and of those one which takes all the words of the text.
+// Of equally specific commands, the first registered one wins.
+func (s *Slacker) matchCommand(text string) *commandMatch {
+	var best *commandMatch
+	for _, cmd := range s.botCommands {
+		m := matchCommand(cmd, text)
+		if m == nil {
+			continue
+		}
+		if best == nil || m.words > best.words || (m.words == best.words && len(best.extra) > 0 && len(m.extra) == 0) {
+			best = m
+		}
 	}
+	return best
+}
 
-	s.botCommands = append([]BotCommand{NewBotCommand(helpCommand, s.helpDefinition)}, s.botCommands...)
+// authorized returns true if the user may run the command of the request according
+// to the roles and authorization funcs of the command and its groups. It is checked
+// before any middleware runs.
+func (s *Slacker) authorized(request Request, response ResponseWriter) bool {
+	cmd, message := request.Command(), request.Message()
+	if !s.mayRunCommand(message.User, message.Channel, cmd) {
+		return false
+	}
+	if cmd.Definition().AuthorizationFunc != nil && !cmd.Definition().AuthorizationFunc(request) {
+		return false
+	}
+	return commandGroup(cmd).authorized(request)
 }
 
-func (s *Slacker) defaultHelp(request Request, response ResponseWriter) {
-	authorizedCommandAvailable := false
-	helpMessage := empty
-	for _, command := range s.botCommands {
-		tokens := command.Tokenize()
-		for _, token := range tokens {
-			if token.IsParameter() {
-				helpMessage += fmt.Sprintf(codeMessageFormat, token.Word) + space
-			} else {
-				helpMessage += fmt.Sprintf(boldMessageFormat, token.Word) + space
-			}
+func (s *Slacker) dispatch(ctx context.Context, message *Message, response ResponseWriter) {
+	if m := s.matchCommand(message.Text); m != nil {
+		cmd := m.command
+		if t, ok := response.(threadReplier); ok && cmd.Definition() != nil && cmd.Definition().ThreadReplies {
+			t.setThreadReplies(true)
 		}
 
-		if len(command.Definition().Description) > 0 {
-			helpMessage += dash + space + fmt.Sprintf(italicMessageFormat, command.Definition().Description)
+		parameters := s.channels.withDefaults(message.Channel, cmd, m.parameters)
+		request := newCommandRequest(ctx, message, parameters, cmd, nil)
+		if !s.channelAllowed(request, response) {
+			return
+		}
+		if len(m.extra) > 0 {
+			response.ReportError(fmt.Errorf(unexpectedArgsFormat, strings.Join(m.extra, space), fmt.Sprintf(codeMessageFormat, cmd.Usage())))
+			return
+		}
+		flags, err := validateFlags(cmd, m.flags)
+		if err != nil {
+			response.ReportError(err)
+			return
+		}
+		parameters, err = validateParams(cmd, parameters, flags)
+		if err != nil {
+			response.ReportError(err)
+			return
+		}
+		request = newCommandRequest(ctx, message, parameters, cmd, flags)
+		// unauthorized attempts must not use up the rate limits
+		if !s.authorized(request, response) {
+			response.ReportError(errNotAuthorized)
+			return
+		}
+		if s.rateLimited(request, response) {
+			return
 		}
 
-		if command.Definition().AuthorizationFunc != nil {
-			authorizedCommandAvailable = true
-			helpMessage += space + fmt.Sprintf(codeMessageFormat, star)
+		release, ok := s.dispatcher.acquire(cmd.Usage())
+		if !ok {
+			metrics.Add(metricDispatchCommandBusy, 1)
+			response.Reply(busyMessage)
+			return
 		}
+		defer release()
+		s.chain(func(request Request, response ResponseWriter) {
+			s.runJob(request, response, func(request Request) {
+				cmd.Execute(request, response)
+			})
+		})(request, response)
+		return
+	}
 
-		helpMessage += newLine
+	runnable := s.runnableCommands(message)
+	if g := s.findGroup(message.Text); g != nil {
+		s.replyBlocks(response, g.Name(), s.groupHelp(g, runnable))
+		return
+	}
 
-		if len(command.Definition().Example) > 0 {
-			helpMessage += fmt.Sprintf(quoteMessageFormat, command.Definition().Example) + newLine
-		}
+	if suggested, ok := s.suggest(message.Text, runnable); ok {
+		s.replySuggestion(response, suggested)
+		return
 	}
 
-	if authorizedCommandAvailable {
-		helpMessage += fmt.Sprintf(codeMessageFormat, star+space+authorizedUsersOnly) + newLine
+	if g := s.leadingGroup(message.Text); g != nil {
+		s.replyBlocks(response, g.Name(), s.groupHelp(g, runnable))
+		return
+	}
+
+	if s.defaultMessageHandler != nil {
+		request := NewMessageRequest(ctx, message, &proper.Properties{})
+		s.chain(s.defaultMessageHandler)(request, response)
 	}
-	response.Reply(helpMessage)
 }
@@ -0,0 +1,163 @@
+package slacker_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sttts/sttts-bot/slacker"
+	"github.com/sttts/sttts-bot/slackertest"
+)
+
+const (
+	testChannel = "C0TEST"
+	testUser    = "U0ALICE"
+	waitTimeout = 5 * time.Second
+)
+
+// newTestBot starts a bot against the fake API, with a "version" command and a
+// "bz stats <group>" command in the bz group replying with the group it got.
+// The setup funcs register more commands and actions before the bot starts.
+func newTestBot(t *testing.T, api *slackertest.Server, setup ...func(bot *slacker.Slacker)) http.Handler {
+	t.Helper()
+	return newTestBotWithOptions(t, api, api.SlackerOptions(), setup...)
+}
+
+// newTestBotWithOptions starts the bot of newTestBot with other options.
+func newTestBotWithOptions(t *testing.T, api *slackertest.Server, opt slacker.Options, setup ...func(bot *slacker.Slacker)) http.Handler {
+	t.Helper()
+
+	bot := slacker.NewSlacker(opt)
+	bot.RBAC(&slacker.RBACConfig{Roles: map[string]slacker.Role{slacker.AdminRole: {Users: []string{testUser}}}})
+	bot.Command("version", &slacker.CommandDefinition{
+		Handler: func(request slacker.Request, response slacker.ResponseWriter) {
+			response.Reply("v1")
+		},
+	})
+	bot.Group("bz").Command("stats <group>", &slacker.CommandDefinition{
+		RootAliases: []string{"bz-stats", "stats"},
+		Handler: func(request slacker.Request, response slacker.ResponseWriter) {
+			response.Reply("stats of group " + request.StringParam("group", "b"))
+		},
+	})
+
+	for _, f := range setup {
+		f(bot)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	handler, err := bot.Handler(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handler
+}
+
+// run injects a mention of the bot with the text and returns the text of the n-th posted message.
+func run(t *testing.T, api *slackertest.Server, handler http.Handler, text string, n int) string {
+	t.Helper()
+
+	if err := slackertest.InjectEvent(handler, slackertest.AppMentionEvent(testChannel, testUser, text)); err != nil {
+		t.Fatal(err)
+	}
+	calls, err := api.WaitForCalls("chat.postMessage", n, waitTimeout)
+	if err != nil {
+		t.Fatalf("%q: %v", text, err)
+	}
+	return calls[n-1].Params.Get("text")
+}
+
+func TestCommands(t *testing.T) {
+	api := slackertest.NewServer()
+	defer api.Close()
+	handler := newTestBot(t, api)
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"bz stats c", "stats of group c"},
+		{"bz-stats d", "stats of group d"},
+		{"stats e", "stats of group e"},
+		{"bz stats", "stats of group b"},
+		{"version", "v1"},
+		{"version now", "unexpected `now`"},
+		{"bz stats c d", "stats of group c d"},
+	}
+	for i, tt := range tests {
+		if got := run(t, api, handler, tt.text, i+1); !strings.Contains(got, tt.want) {
+			t.Errorf("%q replied %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestChannelDefaults(t *testing.T) {
+	api := slackertest.NewServer()
+	defer api.Close()
+	handler := newTestBot(t, api)
+
+	steps := []struct {
+		text string
+		want string
+	}{
+		{"channel default bz stats group c", "Set default `group=c` for `bz stats`"},
+		{"bz stats", "stats of group c"},
+		{"bz stats d", "stats of group d"},
+		{"channel default bz stats group", "for `bz stats`"},
+		{"bz stats", "stats of group b"},
+		{"channel default nothing group c", "unknown command"},
+	}
+	for i, step := range steps {
+		if got := run(t, api, handler, step.text, i+1); !strings.Contains(got, step.want) {
+			t.Errorf("%q replied %q, want %q", step.text, got, step.want)
+		}
+	}
+}
+
+func TestRetriedEvents(t *testing.T) {
+	api := slackertest.NewServer()
+	defer api.Close()
+	handler := newTestBot(t, api)
+
+	event := slackertest.AppMentionEvent(testChannel, testUser, "version")
+	if err := slackertest.InjectEvent(handler, event); err != nil {
+		t.Fatal(err)
+	}
+	// the retry of an answered event is dropped, the one of a lost event is answered
+	if err := slackertest.InjectRetriedEvent(handler, event, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := slackertest.InjectRetriedEvent(handler, slackertest.AppMentionEvent(testChannel, testUser, "bz stats"), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	calls, err := api.WaitForCalls("chat.postMessage", 2, waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := calls[1].Params.Get("text"), "stats of group b"; got != want {
+		t.Errorf("the second reply is %q, want %q", got, want)
+	}
+	// a reply to the retry would be posted by the same single worker before the second one
+	if calls := api.CallsTo("chat.postMessage"); len(calls) != 2 {
+		t.Errorf("got %d replies, want 2", len(calls))
+	}
+}
+
+func TestIgnoreRetries(t *testing.T) {
+	api := slackertest.NewServer()
+	defer api.Close()
+	opt := api.SlackerOptions()
+	opt.IgnoreRetries = true
+	handler := newTestBotWithOptions(t, api, opt)
+
+	if err := slackertest.InjectRetriedEvent(handler, slackertest.AppMentionEvent(testChannel, testUser, "bz stats"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := run(t, api, handler, "version", 1), "v1"; got != want {
+		t.Errorf("replied %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package slacker
+
+import (
+	"fmt"
+
+	"github.com/slack-go/slack"
+)
+
+// NewSlashCommandResponse creates a response structure answering through the response_url of a slash command
+func NewSlashCommandResponse(command *slack.SlashCommand, client *slack.Client) ResponseWriter {
+	return &slashCommandResponse{
+		messenger: messenger{client: client, channel: command.ChannelID, user: command.UserID},
+		command:   command,
+	}
+}
+
+// slashCommandResponse replies to slash commands. Replies are ephemeral unless
+// WithInChannel is passed. There is no thread to reply into, so thread options are
+// ignored, and no message to react to.
+type slashCommandResponse struct {
+	messenger
+	command *slack.SlashCommand
+}
+
+// ReportError sends back a formatted error message visible only to the user who invoked the command
+func (r *slashCommandResponse) ReportError(err error, options ...ReportErrorOption) {
+	r.client.SendMessage(r.command.ChannelID,
+		slack.MsgOptionResponseURL(r.command.ResponseURL, slack.ResponseTypeEphemeral),
+		slack.MsgOptionText(fmt.Sprintf(errorFormat, err.Error()), false),
+	)
+}
+
+// Reply sends a message through the response_url of the slash command. Slack returns no timestamp for it
+func (r *slashCommandResponse) Reply(message string, options ...ReplyOption) (string, error) {
+	defaults := newReplyDefaults(options...)
+
+	responseType := slack.ResponseTypeEphemeral
+	if defaults.InChannel {
+		responseType = slack.ResponseTypeInChannel
+	}
+
+	_, _, _, err := r.client.SendMessage(
+		r.command.ChannelID,
+		slack.MsgOptionResponseURL(r.command.ResponseURL, responseType),
+		slack.MsgOptionText(message, false),
+		slack.MsgOptionAttachments(defaults.Attachments...),
+		slack.MsgOptionBlocks(defaults.Blocks...),
+	)
+	return empty, err
+}
+
+// Update replaces the last reply through the response_url if no timestamp is given, otherwise the message in the channel
+func (r *slashCommandResponse) Update(timestamp string, message string, options ...ReplyOption) (string, error) {
+	if len(timestamp) > 0 {
+		return r.messenger.Update(timestamp, message, options...)
+	}
+
+	defaults := newReplyDefaults(options...)
+	_, _, _, err := r.client.SendMessage(
+		r.command.ChannelID,
+		slack.MsgOptionReplaceOriginal(r.command.ResponseURL),
+		slack.MsgOptionText(message, false),
+		slack.MsgOptionAttachments(defaults.Attachments...),
+		slack.MsgOptionBlocks(defaults.Blocks...),
+	)
+	return empty, err
+}
+
+// Progress returns a status message handle, updated through the response_url
+func (r *slashCommandResponse) Progress() Progress {
+	return newProgress(r)
+}
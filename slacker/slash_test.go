@@ -0,0 +1,46 @@
+package slacker_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+
+	"github.com/sttts/sttts-bot/slackertest"
+)
+
+func TestSlashCommands(t *testing.T) {
+	api := slackertest.NewServer()
+	defer api.Close()
+	handler := newTestBot(t, api)
+
+	tests := []struct {
+		command, text string
+		want          string
+	}{
+		{"/bz", "stats c", "stats of group c"},
+		{"/bz", "stats", "stats of group b"},
+		{"/version", "", "v1"},
+		{"/version", "now", "unexpected `now`"},
+	}
+	for i, tt := range tests {
+		body := slackertest.SlashCommand(tt.command, testChannel, testUser, tt.text, api.ResponseURL())
+		if err := slackertest.InjectSlashCommand(handler, body); err != nil {
+			t.Fatal(err)
+		}
+		calls, err := api.WaitForCalls(slackertest.ResponseURLMethod, i+1, waitTimeout)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.command, tt.text, err)
+		}
+		call := calls[i]
+		if got := call.Params.Get("text"); !strings.Contains(got, tt.want) {
+			t.Errorf("%s %s replied %q, want %q", tt.command, tt.text, got, tt.want)
+		}
+		if got := call.Params.Get("response_type"); got != slack.ResponseTypeEphemeral {
+			t.Errorf("%s %s replied with response_type %q, want %q", tt.command, tt.text, got, slack.ResponseTypeEphemeral)
+		}
+	}
+	if calls := api.CallsTo("chat.postMessage"); len(calls) > 0 {
+		t.Errorf("slash commands posted %d messages to the channel", len(calls))
+	}
+}
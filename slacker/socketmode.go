@@ -0,0 +1,167 @@
+package slacker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"k8s.io/klog"
+)
+
+const (
+	socketModeConnectionsOpen = "apps.connections.open"
+
+	socketModeHello       = "hello"
+	socketModeDisconnect  = "disconnect"
+	socketModeEventsAPI   = "events_api"
+	socketModeSlash       = "slash_commands"
+	socketModeInteractive = "interactive"
+)
+
+var errSocketModeDisconnect = errors.New("disconnect requested by Slack")
+
+// socketModeReconnectDelay is the wait before opening a new connection after a failure.
+var socketModeReconnectDelay = 5 * time.Second
+
+// socketModeEnvelope is the frame Slack sends over a Socket Mode connection,
+// see https://api.slack.com/apis/connections/socket-implement.
+type socketModeEnvelope struct {
+	Type         string          `json:"type"`
+	EnvelopeID   string          `json:"envelope_id"`
+	Payload      json.RawMessage `json:"payload"`
+	RetryAttempt int             `json:"retry_attempt"`
+	RetryReason  string          `json:"retry_reason"`
+	Reason       string          `json:"reason"`
+}
+
+// socketModeAck acknowledges an envelope. Slack retries unacknowledged envelopes.
+type socketModeAck struct {
+	EnvelopeID string      `json:"envelope_id"`
+	Payload    interface{} `json:"payload,omitempty"`
+}
+
+// listenSocketMode receives events over Socket Mode until the context is done,
+// reconnecting whenever Slack asks for it or the connection drops.
+func (s *Slacker) listenSocketMode(ctx context.Context, client *slack.Client) error {
+	klog.Infof("sttts-bot up and listening to slack via Socket Mode")
+	for {
+		// failures to open a connection, e.g. DNS errors or 5xx answers, are as transient as dropped connections
+		url, err := s.openSocketModeConnection(ctx)
+		if err == nil {
+			err = s.runSocketModeConnection(ctx, client, url)
+		}
+		if ctx.Err() != nil {
+			klog.Infof("Shutting down")
+			return nil
+		}
+		if err == errSocketModeDisconnect {
+			klog.Infof("Socket Mode connection closed by Slack, reconnecting")
+			continue
+		}
+
+		klog.Warningf("Socket Mode connection failed, reconnecting in %v: %v", socketModeReconnectDelay, err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(socketModeReconnectDelay):
+		}
+	}
+}
+
+// openSocketModeConnection asks Slack for a fresh websocket URL using the app-level token.
+func (s *Slacker) openSocketModeConnection(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.clientDefaults.APIURL+socketModeConnectionsOpen, nil)
+	if err != nil {
+		return empty, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.appToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.clientDefaults.HTTPClient.Do(req)
+	if err != nil {
+		return empty, fmt.Errorf("failed to call %s: %v", socketModeConnectionsOpen, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		slack.SlackResponse
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return empty, fmt.Errorf("failed to decode %s response: %v", socketModeConnectionsOpen, err)
+	}
+	if !result.Ok {
+		return empty, fmt.Errorf("%s failed: %s", socketModeConnectionsOpen, result.Error)
+	}
+
+	return result.URL, nil
+}
+
+// runSocketModeConnection reads envelopes from one websocket connection, acks
+// them and dispatches events through the same path as the HTTP endpoint.
+func (s *Slacker) runSocketModeConnection(ctx context.Context, client *slack.Client, url string) error {
+	conn, _, err := s.websocketDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		var envelope socketModeEnvelope
+		if err := conn.ReadJSON(&envelope); err != nil {
+			return err
+		}
+
+		if len(envelope.EnvelopeID) > 0 {
+			if err := conn.WriteJSON(socketModeAck{EnvelopeID: envelope.EnvelopeID}); err != nil {
+				return err
+			}
+		}
+
+		switch envelope.Type {
+		case socketModeHello:
+			klog.Infof("Socket Mode connection established")
+		case socketModeDisconnect:
+			klog.V(2).Infof("Socket Mode disconnect: %s", envelope.Reason)
+			return errSocketModeDisconnect
+		case socketModeEventsAPI:
+			eventsAPIEvent, err := slackevents.ParseEvent(envelope.Payload, slackevents.OptionNoVerifyToken())
+			if err != nil {
+				klog.Warningf("Failed to parse Socket Mode event: %v", err)
+				continue
+			}
+			s.handleEventsAPIEvent(client, eventsAPIEvent, envelope.RetryAttempt)
+		case socketModeSlash:
+			var command slack.SlashCommand
+			if err := json.Unmarshal(envelope.Payload, &command); err != nil {
+				klog.Warningf("Failed to parse Socket Mode slash command: %v", err)
+				continue
+			}
+			s.handleSlashCommand(client, &command)
+		case socketModeInteractive:
+			var callback slack.InteractionCallback
+			if err := json.Unmarshal(envelope.Payload, &callback); err != nil {
+				klog.Warningf("Failed to parse Socket Mode interaction: %v", err)
+				continue
+			}
+			s.handleInteraction(client, &callback)
+		default:
+			klog.V(2).Infof("Ignoring Socket Mode envelope of type %q", envelope.Type)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package slacker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestListenSocketMode(t *testing.T) {
+	defer func(delay time.Duration) { socketModeReconnectDelay = delay }(socketModeReconnectDelay)
+	socketModeReconnectDelay = 10 * time.Millisecond
+
+	var lock sync.Mutex
+	var opens int
+	acks := make(chan string, 10)
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/api/"+socketModeConnectionsOpen, func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		opens++
+		if r.Header.Get("Authorization") != "Bearer xapp-test" {
+			t.Errorf("unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+		// the first attempt fails like an unavailable Slack
+		if opens == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "url": "ws" + strings.TrimPrefix(server.URL, "http") + "/socket"})
+	})
+	mux.HandleFunc("/socket", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteJSON(map[string]interface{}{"type": socketModeHello})
+		conn.WriteJSON(map[string]interface{}{
+			"type":        socketModeEventsAPI,
+			"envelope_id": "envelope-1",
+			"payload": map[string]interface{}{
+				"type":     "event_callback",
+				"event_id": "Ev1",
+				"event":    map[string]interface{}{"type": "app_mention", "channel": "C1", "user": "U1", "text": "<@UBOT> ping", "ts": "1.1"},
+			},
+		})
+		for {
+			var ack socketModeAck
+			if err := conn.ReadJSON(&ack); err != nil {
+				return
+			}
+			acks <- ack.EnvelopeID
+		}
+	})
+
+	s := NewSlacker(Options{AppToken: "xapp-test", Transport: SocketModeTransport, APIURL: server.URL + "/api/", Workers: 1, QueueSize: 1})
+	s.botUserID = "UBOT"
+	pinged := make(chan string, 1)
+	s.Command("ping", &CommandDefinition{Handler: func(request Request, response ResponseWriter) {
+		pinged <- request.Message().User
+	}})
+	s.prependHelpHandle()
+	defer s.dispatcher.start()()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.listenSocketMode(ctx, s.newClient()) }()
+
+	select {
+	case id := <-acks:
+		if id != "envelope-1" {
+			t.Errorf("acked %q, expected envelope-1", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the envelope was not acked")
+	}
+	select {
+	case user := <-pinged:
+		if user != "U1" {
+			t.Errorf("ping run for %q, expected U1", user)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the event was not dispatched")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if opens < 2 {
+		t.Errorf("%s was called %d times, expected a retry", socketModeConnectionsOpen, opens)
+	}
+}
@@ -0,0 +1,158 @@
+package slacker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"k8s.io/klog"
+)
+
+const (
+	runSuggestionActionID = "slacker.run-suggestion"
+	suggestionFormat      = "Unknown command. Did you mean `%s`?"
+	runningFormat         = "Running `%s`..."
+	maxSuggestionWords    = 3
+)
+
+// suggestion is a command text the user probably meant
+type suggestion struct {
+	text  string
+	score int
+}
+
+// suggest ranks the commands the user may run by edit distance of their names
+// and aliases to the beginning of the text, and by shared words. It returns
+// the best match with the user's arguments appended, or false if nothing is close.
+func (s *Slacker) suggest(text string, commands []BotCommand) (string, bool) {
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) == 0 {
+		return empty, false
+	}
+	// only the first words are taken into account, the rest are probably arguments
+	leading := words
+	if len(leading) > maxSuggestionWords {
+		leading = leading[:maxSuggestionWords]
+	}
+	textTokens := nameTokens(strings.Join(leading, space))
+
+	var best *suggestion
+	for _, command := range commands {
+		for _, name := range commandNames(command) {
+			n := len(strings.Fields(name))
+			if n == 0 || n > len(words) {
+				continue
+			}
+			typed := strings.Join(words[:n], space)
+
+			distance := editDistance(typed, name)
+			overlap := 0
+			for token := range nameTokens(name) {
+				if textTokens[token] {
+					overlap++
+				}
+			}
+			if distance > maxSuggestionDistance(name) && overlap == 0 {
+				continue
+			}
+
+			score := distance - 2*overlap
+			if best == nil || score < best.score {
+				best = &suggestion{
+					text:  strings.Join(append([]string{commandName(command)}, strings.Fields(text)[n:]...), space),
+					score: score,
+				}
+			}
+		}
+	}
+
+	if best == nil {
+		return empty, false
+	}
+	return best.text, true
+}
+
+// replySuggestion offers to run the suggested command with a button.
+func (s *Slacker) replySuggestion(response ResponseWriter, suggested string) {
+	text := fmt.Sprintf(suggestionFormat, suggested)
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock(empty, slack.NewButtonBlockElement(runSuggestionActionID, suggested, slack.NewTextBlockObject(slack.PlainTextType, "Run it", false, false))),
+	}
+	if _, err := response.Reply(text, WithBlocks(blocks)); err != nil {
+		klog.Error(err)
+	}
+}
+
+// runSuggestion queues the command text of a clicked suggestion button as if the user typed it.
+func (s *Slacker) runSuggestion(request ActionRequest, response ActionResponseWriter) {
+	callback := request.Callback()
+	text := request.Value()
+	if err := response.UpdateOriginal(fmt.Sprintf(runningFormat, text)); err != nil {
+		klog.Error(err)
+	}
+
+	// fake message event, dispatched by a worker of its own outside of the middlewares of the action
+	s.handleMessage(response.Client(), &slackevents.MessageEvent{
+		Type:            slackevents.Message,
+		User:            callback.User.ID,
+		Text:            text,
+		Channel:         callback.Channel.ID,
+		ThreadTimeStamp: callback.Message.ThreadTimestamp,
+	})
+}
+
+// maxSuggestionDistance allows roughly one typo per three characters
+func maxSuggestionDistance(name string) int {
+	if d := len(name) / 3; d > 1 {
+		return d
+	}
+	return 1
+}
+
+// nameTokens splits a command name into its words, e.g. "bz-stats" into "bz" and "stats"
+func nameTokens(name string) map[string]bool {
+	tokens := map[string]bool{}
+	for _, token := range strings.FieldsFunc(name, func(r rune) bool { return r == ' ' || r == '-' || r == '_' }) {
+		tokens[token] = true
+	}
+	return tokens
+}
+
+// editDistance returns the optimal string alignment distance of a and b, i.e.
+// the Levenshtein distance which also counts swapped neighbours as one edit
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
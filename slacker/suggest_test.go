@@ -0,0 +1,49 @@
+package slacker
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"stats", "stats", 0},
+		{"stast", "stats", 1}, // transposition
+		{"stat", "stats", 1},
+		{"bz stats", "bz-stats", 1},
+		{"", "help", 4},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	s := &Slacker{}
+	commands := []BotCommand{
+		NewBotCommand("version", nil),
+		NewBotCommand("bz stats <group>", &CommandDefinition{Aliases: []string{"bz-stats"}}),
+		NewBotCommand("bz query", nil),
+	}
+
+	tests := []struct {
+		text string
+		want string
+		ok   bool
+	}{
+		{"verison", "version", true},
+		{"bz stast b", "bz stats b", true},
+		{"bz-stat c", "bz stats c", true},
+		{"completely unrelated", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := s.suggest(tt.text, commands)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("suggest(%q) = %q, %v, want %q, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
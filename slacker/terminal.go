@@ -0,0 +1,205 @@
+package slacker
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// TerminalUser is the user ID commands run with Exec and REPL are sent by, unless another is given
+	TerminalUser = "ULOCAL"
+	// terminalChannel is a direct message channel, so channel configuration does not apply
+	terminalChannel = "DLOCAL"
+
+	terminalPrompt        = "> "
+	terminalTimestamp     = "%d.000000"
+	terminalEphemeral     = "(only visible to you) "
+	terminalUpdateFormat  = "(edited %s) "
+	terminalDeleteFormat  = "(deleted %s)"
+	terminalReactFormat   = "(reacted :%s:)"
+	terminalFileFormat    = "(uploaded %s, %d bytes)"
+	terminalDMFormat      = "(direct message to %s) "
+	maxTerminalFileLength = 64 * 1024
+)
+
+var terminalExitWords = []string{"exit", "quit"}
+
+var (
+	// ErrUnknownCommand is returned by Exec if the text is neither a command nor a group
+	ErrUnknownCommand = errors.New("unknown command")
+	errCommandFailed  = errors.New("command failed")
+)
+
+// Exec runs the text as command of the user, as if sent in a direct message to
+// the bot, and writes the responses as text to out. It returns when the command finished,
+// with ErrUnknownCommand if there is no such command, or with an error if the command
+// reported one or its progress failed.
+// No connection to a chat platform is needed.
+func (s *Slacker) Exec(ctx context.Context, user, text string, out io.Writer) error {
+	s.prependHelpHandle()
+	message := &Message{
+		Platform: TerminalPlatform,
+		User:     user,
+		Channel:  terminalChannel,
+		Text:     strings.TrimSpace(text),
+		Direct:   true,
+	}
+	response := newTerminalResponse(out)
+	s.dispatch(ctx, message, response)
+	if s.matchCommand(message.Text) == nil && s.findGroup(message.Text) == nil {
+		return ErrUnknownCommand
+	}
+	return response.failure()
+}
+
+// REPL reads commands line by line from in and runs them with Exec, until the
+// input ends, the user types exit or quit, or the context is done.
+func (s *Slacker) REPL(ctx context.Context, user string, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, terminalPrompt)
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case len(text) == 0:
+			continue
+		case contains(terminalExitWords, strings.ToLower(text)):
+			return nil
+		}
+
+		// the errors were written to out already
+		_ = s.Exec(ctx, user, text, out)
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// terminalResponse writes the responses as text, with blocks and attachments rendered line by line.
+type terminalResponse struct {
+	out io.Writer
+
+	lock     sync.Mutex
+	messages int
+	// err is the first error the command reported
+	err error
+}
+
+func newTerminalResponse(out io.Writer) *terminalResponse {
+	return &terminalResponse{out: out}
+}
+
+// print writes the message and returns its made-up timestamp.
+func (r *terminalResponse) print(prefix, text string, options ...ReplyOption) string {
+	defaults := newReplyDefaults(options...)
+
+	// like Slack, show the text only as fallback of the blocks
+	lines := renderBlocks(defaults.Blocks, plainText)
+	if len(lines) == 0 {
+		lines = []string{plainText(text)}
+	}
+	lines[0] = prefix + lines[0]
+	lines = append(lines, renderAttachments(defaults.Attachments, plainText)...)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.messages++
+	fmt.Fprintln(r.out, strings.TrimRight(strings.Join(lines, newLine), newLine))
+	return fmt.Sprintf(terminalTimestamp, r.messages)
+}
+
+// failure returns the first error the command reported, if any.
+func (r *terminalResponse) failure() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+// fail remembers the first error the command reported.
+func (r *terminalResponse) fail(err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// note writes a line about something which is no message, like a reaction.
+func (r *terminalResponse) note(text string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	fmt.Fprintln(r.out, text)
+}
+
+// Reply writes the message
+func (r *terminalResponse) Reply(text string, options ...ReplyOption) (string, error) {
+	return r.print(empty, text, options...), nil
+}
+
+// ReplyEphemeral writes the message, marked as ephemeral
+func (r *terminalResponse) ReplyEphemeral(text string, options ...ReplyOption) (string, error) {
+	return r.print(terminalEphemeral, text, options...), nil
+}
+
+// Update writes the new version of the message
+func (r *terminalResponse) Update(timestamp string, text string, options ...ReplyOption) (string, error) {
+	if len(timestamp) == 0 {
+		return empty, fmt.Errorf("there is no message to update")
+	}
+	r.print(fmt.Sprintf(terminalUpdateFormat, timestamp), text, options...)
+	return timestamp, nil
+}
+
+// Delete writes a note about the deleted message
+func (r *terminalResponse) Delete(timestamp string) error {
+	r.note(fmt.Sprintf(terminalDeleteFormat, timestamp))
+	return nil
+}
+
+// React writes a note about the reaction. A failed progress reacts with a cross
+func (r *terminalResponse) React(emoji string) error {
+	if emoji == failedReaction {
+		r.fail(errCommandFailed)
+	}
+	r.note(fmt.Sprintf(terminalReactFormat, strings.Trim(emoji, ":")))
+	return nil
+}
+
+// UploadFile writes the file name, and the content if it is text of reasonable size
+func (r *terminalResponse) UploadFile(name string, content io.Reader) (string, error) {
+	bs, err := ioutil.ReadAll(content)
+	if err != nil {
+		return empty, err
+	}
+	text := fmt.Sprintf(terminalFileFormat, name, len(bs))
+	if len(bs) <= maxTerminalFileLength && utf8.Valid(bs) {
+		text += newLine + string(bs)
+	}
+	return r.print(empty, text), nil
+}
+
+// DM writes the message, marked with its recipient
+func (r *terminalResponse) DM(user string, text string, options ...ReplyOption) (string, error) {
+	return r.print(fmt.Sprintf(terminalDMFormat, user), text, options...), nil
+}
+
+// Progress returns a status message handle writing each status
+func (r *terminalResponse) Progress() Progress {
+	return newProgress(r)
+}
+
+// ReportError writes the error
+func (r *terminalResponse) ReportError(err error, options ...ReportErrorOption) {
+	r.fail(err)
+	r.print(empty, fmt.Sprintf(errorFormat, err.Error()))
+}
@@ -0,0 +1,85 @@
+package slacker_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sttts/sttts-bot/slacker"
+)
+
+func newTerminalBot() *slacker.Slacker {
+	bot := slacker.NewSlacker(slacker.Options{Workers: 1})
+	bot.Command("version", &slacker.CommandDefinition{
+		Handler: func(request slacker.Request, response slacker.ResponseWriter) {
+			response.Reply("v1 for " + request.Message().User)
+		},
+	})
+	bot.Command("broken", &slacker.CommandDefinition{
+		Handler: func(request slacker.Request, response slacker.ResponseWriter) {
+			response.ReportError(errors.New("boom"))
+		},
+	})
+	bot.Command("stats", &slacker.CommandDefinition{
+		Handler: func(request slacker.Request, response slacker.ResponseWriter) {
+			progress := response.Progress()
+			progress.Update("querying")
+			progress.Fail(errors.New("bugzilla is down"))
+		},
+	})
+	return bot
+}
+
+func TestExec(t *testing.T) {
+	bot := newTerminalBot()
+
+	tests := []struct {
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{"version", "v1 for " + slacker.TerminalUser, false},
+		{"broken", "boom", true},
+		{"stats", "bugzilla is down", true},
+		{"version now", "unexpected `now`", true},
+		{"nothing", "", true},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		err := bot.Exec(context.Background(), slacker.TerminalUser, tt.text, &out)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q returned error %v, want error %v", tt.text, err, tt.wantErr)
+		}
+		if !strings.Contains(out.String(), tt.want) {
+			t.Errorf("%q wrote %q, want %q", tt.text, out.String(), tt.want)
+		}
+	}
+
+	err := bot.Exec(context.Background(), slacker.TerminalUser, "nothing", &bytes.Buffer{})
+	if err != slacker.ErrUnknownCommand {
+		t.Errorf("an unknown command returned %v, want %v", err, slacker.ErrUnknownCommand)
+	}
+}
+
+func TestREPL(t *testing.T) {
+	bot := newTerminalBot()
+
+	var out bytes.Buffer
+	in := strings.NewReader("version\n\nbroken\nquit\nversion\n")
+	if err := bot.REPL(context.Background(), "U0ALICE", in, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	// the commands after quit are not run
+	if got := strings.Count(out.String(), "v1 for U0ALICE"); got != 1 {
+		t.Errorf("version ran %d times, want once:\n%s", got, out.String())
+	}
+	if !strings.Contains(out.String(), "boom") {
+		t.Errorf("the error of broken is missing:\n%s", out.String())
+	}
+	if got := strings.Count(out.String(), "> "); got != 4 {
+		t.Errorf("got %d prompts, want 4:\n%s", got, out.String())
+	}
+}
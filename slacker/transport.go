@@ -0,0 +1,24 @@
+package slacker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// errNoUserGroups is returned by platforms without user groups.
+var errNoUserGroups = errors.New("the platform has no user groups")
+
+// transport connects the Slacker to a chat platform. It receives the messages,
+// converts them into Messages and dispatches them with ResponseWriters replying
+// on the platform.
+type transport interface {
+	// connect identifies the bot user and returns its ID.
+	connect(ctx context.Context) (string, error)
+	// listen receives messages until the context is done.
+	listen(ctx context.Context) error
+	// handler returns the HTTP handler of the endpoints receiving the messages.
+	handler() http.Handler
+	// userGroupMembers returns the IDs of the members of a user group, see Role.UserGroups.
+	userGroupMembers(group string) ([]string, error)
+}
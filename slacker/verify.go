@@ -0,0 +1,164 @@
+package slacker
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	signatureHeader  = "X-Slack-Signature"
+	timestampHeader  = "X-Slack-Request-Timestamp"
+	signatureVersion = "v0"
+
+	// maxRequestBodySize limits what we read from Slack before verification.
+	maxRequestBodySize = 1 << 20
+)
+
+var (
+	errMissingSignature = errors.New("missing request signature headers")
+	errInvalidSignature = errors.New("invalid request signature")
+	errExpiredTimestamp = errors.New("request timestamp outside of replay window")
+	errInvalidToken     = errors.New(invalidToken)
+)
+
+// requestVerifier checks that a request body and its headers were sent by Slack.
+type requestVerifier interface {
+	Verify(header http.Header, body []byte) error
+}
+
+// signatureVerifier implements Slack's HMAC-SHA256 "v0" request signing,
+// see https://api.slack.com/authentication/verifying-requests-from-slack.
+type signatureVerifier struct {
+	secret string
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+func (v *signatureVerifier) Verify(header http.Header, body []byte) error {
+	signature := header.Get(signatureHeader)
+	stimestamp := header.Get(timestampHeader)
+	if len(signature) == 0 || len(stimestamp) == 0 {
+		return errMissingSignature
+	}
+
+	timestamp, err := strconv.ParseInt(stimestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s header %q: %v", timestampHeader, stimestamp, err)
+	}
+	age := v.now().Sub(time.Unix(timestamp, 0))
+	if age < -v.maxAge || age > v.maxAge {
+		return errExpiredTimestamp
+	}
+
+	if !strings.HasPrefix(signature, signatureVersion+"=") {
+		return errInvalidSignature
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, signatureVersion+"="))
+	if err != nil {
+		return errInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(v.secret))
+	fmt.Fprintf(mac, "%s:%s:", signatureVersion, stimestamp)
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return errInvalidSignature
+	}
+
+	return nil
+}
+
+// tokenVerifier implements the deprecated verification token check. The token
+// is sent inside the payload, either as JSON (Events API), as form value
+// (slash commands) or as JSON in the "payload" form value (interactions).
+type tokenVerifier struct {
+	token string
+}
+
+func (v *tokenVerifier) Verify(header http.Header, body []byte) error {
+	if subtle.ConstantTimeCompare([]byte(payloadToken(body)), []byte(v.token)) != 1 {
+		return errInvalidToken
+	}
+	return nil
+}
+
+func payloadToken(body []byte) string {
+	var payload struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil {
+		return payload.Token
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return empty
+	}
+	if token := values.Get("token"); len(token) > 0 {
+		return token
+	}
+	if err := json.Unmarshal([]byte(values.Get("payload")), &payload); err == nil {
+		return payload.Token
+	}
+	return empty
+}
+
+// newRequestVerifier returns the verifier configured by the options. The
+// signing secret always wins, the verification token is only an opt-in fallback.
+func newRequestVerifier(opt Options) requestVerifier {
+	if len(opt.SigningSecret) > 0 {
+		return &signatureVerifier{secret: opt.SigningSecret, maxAge: opt.SignatureMaxAge, now: time.Now}
+	}
+	if opt.AllowVerificationToken && len(opt.VerificationToken) > 0 {
+		klog.Warningf("Verifying Slack requests with the deprecated verification token. Set SLACK_SIGNING_SECRET instead.")
+		return &tokenVerifier{token: opt.VerificationToken}
+	}
+	return nil
+}
+
+// verified wraps a handler such that it is only called for requests which pass
+// verification. The body is read and handed to the handler, but it is also
+// restored on the request for form parsing.
+func verified(verifier requestVerifier, handler func(w http.ResponseWriter, r *http.Request, body []byte)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+		if err != nil {
+			klog.Warningf("Failed to read request body from %s: %v", r.RemoteAddr, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if verifier == nil {
+			klog.Errorf("Rejecting request from %s: no request verification configured", r.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := verifier.Verify(r.Header, body); err != nil {
+			klog.Warningf("Rejecting unverified request to %s from %s: %v", r.URL.Path, r.RemoteAddr, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		handler(w, r, body)
+	}
+}
@@ -0,0 +1,73 @@
+package slacker
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedHeader(secret string, timestamp time.Time, body []byte) http.Header {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":"))
+	mac.Write(body)
+
+	header := http.Header{}
+	header.Set(timestampHeader, ts)
+	header.Set(signatureHeader, "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return header
+}
+
+func TestSignatureVerifier(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	body := []byte(`{"type":"event_callback"}`)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   []byte
+		want   error
+	}{
+		{"valid", signedHeader("secret", now, body), body, nil},
+		{"valid within the window", signedHeader("secret", now.Add(-4*time.Minute), body), body, nil},
+		{"wrong secret", signedHeader("other", now, body), body, errInvalidSignature},
+		{"tampered body", signedHeader("secret", now, body), []byte(`{"type":"other"}`), errInvalidSignature},
+		{"replayed", signedHeader("secret", now.Add(-6*time.Minute), body), body, errExpiredTimestamp},
+		{"from the future", signedHeader("secret", now.Add(6*time.Minute), body), body, errExpiredTimestamp},
+		{"missing headers", http.Header{}, body, errMissingSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &signatureVerifier{secret: "secret", maxAge: 5 * time.Minute, now: func() time.Time { return now }}
+			if err := v.Verify(tt.header, tt.body); err != tt.want {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenVerifier(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want error
+	}{
+		{"json", `{"token":"secret"}`, nil},
+		{"form", `token=secret&text=hello`, nil},
+		{"interaction payload", `payload=%7B%22token%22%3A%22secret%22%7D`, nil},
+		{"wrong token", `{"token":"other"}`, errInvalidToken},
+		{"no token", `{}`, errInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &tokenVerifier{token: "secret"}
+			if err := v.Verify(http.Header{}, []byte(tt.body)); err != tt.want {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package slackertest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var eventCounter int64
+
+// MessageEvent returns the body of an Events API callback with a message event.
+// Mention the bot with "<@UBOT> " in the text for channel messages.
+func MessageEvent(channel, user, text string) []byte {
+	return callbackEvent(map[string]interface{}{
+		"type":    "message",
+		"channel": channel,
+		"user":    user,
+		"text":    text,
+		"ts":      nextTimestamp(),
+	})
+}
+
+// ThreadMessageEvent returns the body of an Events API callback with a message in a thread.
+func ThreadMessageEvent(channel, user, text, threadTimestamp string) []byte {
+	return callbackEvent(map[string]interface{}{
+		"type":      "message",
+		"channel":   channel,
+		"user":      user,
+		"text":      text,
+		"ts":        nextTimestamp(),
+		"thread_ts": threadTimestamp,
+	})
+}
+
+// AppMentionEvent returns the body of an Events API callback with an app_mention
+// event. The mention of the bot is prepended to the text.
+func AppMentionEvent(channel, user, text string) []byte {
+	return callbackEvent(map[string]interface{}{
+		"type":    "app_mention",
+		"channel": channel,
+		"user":    user,
+		"text":    fmt.Sprintf("<@%s> %s", BotUserID, text),
+		"ts":      nextTimestamp(),
+	})
+}
+
+func callbackEvent(event map[string]interface{}) []byte {
+	id := atomic.AddInt64(&eventCounter, 1)
+	bs, _ := json.Marshal(map[string]interface{}{
+		"type":       "event_callback",
+		"team_id":    TeamID,
+		"api_app_id": "A0TEST",
+		"event_id":   fmt.Sprintf("Ev%08d", id),
+		"event_time": time.Now().Unix(),
+		"event":      event,
+	})
+	return bs
+}
+
+// SlashCommand returns the form body of a slash command, answering to the response_url.
+func SlashCommand(command, channel, user, text, responseURL string) []byte {
+	return []byte(url.Values{
+		"command":      {command},
+		"text":         {text},
+		"channel_id":   {channel},
+		"user_id":      {user},
+		"team_id":      {TeamID},
+		"response_url": {responseURL},
+		"trigger_id":   {fmt.Sprintf("trigger-%d", atomic.AddInt64(&eventCounter, 1))},
+	}.Encode())
+}
+
+// NewSignedRequest returns a POST request to the path, signed with the secret like Slack does.
+func NewSignedRequest(secret, path, contentType string, body []byte) *http.Request {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+
+	r := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	r.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	r.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+// InjectEvent posts the Events API body signed with SigningSecret to the /events
+// endpoint of the handler. The command runs asynchronously afterwards, use
+// Server.WaitForCalls to wait for its replies.
+func InjectEvent(handler http.Handler, body []byte) error {
+	return inject(handler, NewSignedRequest(SigningSecret, "/events", "application/json", body))
+}
+
+// InjectRetriedEvent posts the Events API body like InjectEvent, marked as the given retry like Slack does after failed deliveries.
+func InjectRetriedEvent(handler http.Handler, body []byte, retry int) error {
+	r := NewSignedRequest(SigningSecret, "/events", "application/json", body)
+	r.Header.Set("X-Slack-Retry-Num", strconv.Itoa(retry))
+	r.Header.Set("X-Slack-Retry-Reason", "http_timeout")
+	return inject(handler, r)
+}
+
+// InjectSlashCommand posts the slash command body signed with SigningSecret to the /commands endpoint of the handler.
+func InjectSlashCommand(handler http.Handler, body []byte) error {
+	return inject(handler, NewSignedRequest(SigningSecret, "/commands", "application/x-www-form-urlencoded", body))
+}
+
+// InjectInteraction posts the interaction payload signed with SigningSecret to the /interactions endpoint of the handler.
+func InjectInteraction(handler http.Handler, payload []byte) error {
+	body := []byte(url.Values{"payload": {string(payload)}}.Encode())
+	return inject(handler, NewSignedRequest(SigningSecret, "/interactions", "application/x-www-form-urlencoded", body))
+}
+
+func inject(handler http.Handler, r *http.Request) error {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		return fmt.Errorf("%s returned %d: %s", r.URL.Path, w.Code, strings.TrimSpace(w.Body.String()))
+	}
+	return nil
+}
+
+var timestampCounter int64
+
+// nextTimestamp returns a unique message timestamp, distinct from the ones of the server.
+func nextTimestamp() string {
+	return fmt.Sprintf("1500000000.%06d", atomic.AddInt64(&timestampCounter, 1))
+}
@@ -0,0 +1,137 @@
+package slackertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+func TestServer(t *testing.T) {
+	api := NewServer()
+	defer api.Close()
+	api.AddUser("U0ALICE", "alice")
+	api.AddUserGroup("S0DEVS", "U0ALICE")
+	client := slack.New(Token, slack.OptionAPIURL(api.APIURL()))
+
+	auth, err := client.AuthTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth.UserID != BotUserID || auth.TeamID != TeamID {
+		t.Errorf("auth.test returned %s in %s, want %s in %s", auth.UserID, auth.TeamID, BotUserID, TeamID)
+	}
+
+	_, first, err := client.PostMessage("C1", slack.MsgOptionText("hello", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, second, err := client.PostMessage("C1", slack.MsgOptionText("again", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("two messages got the same timestamp %s", first)
+	}
+
+	user, err := client.GetUserInfo("U0ALICE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "alice" {
+		t.Errorf("users.info returned %q, want alice", user.Name)
+	}
+	if _, err := client.GetUserInfo("U0NOBODY"); err == nil || err.Error() != "user_not_found" {
+		t.Errorf("users.info of an unknown user returned %v, want user_not_found", err)
+	}
+	members, err := client.GetUserGroupMembers("S0DEVS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members[0] != "U0ALICE" {
+		t.Errorf("usergroups.users.list returned %v", members)
+	}
+	if _, err := client.GetChannels(false); err == nil || err.Error() != "unknown_method" {
+		t.Errorf("an unknown method returned %v, want unknown_method", err)
+	}
+
+	if _, _, _, err := client.SendMessage("C1", slack.MsgOptionResponseURL(api.ResponseURL(), slack.ResponseTypeEphemeral), slack.MsgOptionText("psst", false)); err != nil {
+		t.Fatal(err)
+	}
+
+	calls, err := api.WaitForCalls("chat.postMessage", 2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := calls[1].Params.Get("text"); got != "again" {
+		t.Errorf("recorded text %q, want again", got)
+	}
+	responses := api.CallsTo(ResponseURLMethod)
+	if len(responses) != 1 || responses[0].Params.Get("text") != "psst" || responses[0].Params.Get("response_type") != slack.ResponseTypeEphemeral {
+		t.Errorf("unexpected response_url calls %+v", responses)
+	}
+
+	if _, err := api.WaitForCalls("chat.postMessage", 3, 10*time.Millisecond); err == nil {
+		t.Errorf("waiting for a third message did not time out")
+	}
+	api.Reset()
+	if calls := api.Calls(); len(calls) != 0 {
+		t.Errorf("%d calls left after Reset", len(calls))
+	}
+}
+
+func TestNewSignedRequest(t *testing.T) {
+	body := AppMentionEvent("C1", "U1", "help")
+	r := NewSignedRequest(SigningSecret, "/events", "application/json", body)
+
+	verifier, err := slack.NewSecretsVerifier(r.Header, SigningSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier.Write(body)
+	if err := verifier.Ensure(); err != nil {
+		t.Errorf("the signature does not verify: %v", err)
+	}
+
+	if bs, _ := ioutil.ReadAll(r.Body); !bytes.Equal(bs, body) {
+		t.Errorf("the request body was changed")
+	}
+	var event struct {
+		Event struct {
+			Text string `json:"text"`
+		} `json:"event"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if want := "<@" + BotUserID + "> help"; event.Event.Text != want {
+		t.Errorf("the app mention text is %q, want %q", event.Event.Text, want)
+	}
+	if bytes.Equal(body, AppMentionEvent("C1", "U1", "help")) {
+		t.Errorf("two events are equal, their IDs must differ")
+	}
+}
+
+func TestInjectEvent(t *testing.T) {
+	var got *http.Request
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		if r.URL.Path != "/events" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	if err := InjectRetriedEvent(handler, MessageEvent("C1", "U1", "help"), 2); err != nil {
+		t.Fatal(err)
+	}
+	if got.Header.Get("X-Slack-Retry-Num") != "2" {
+		t.Errorf("X-Slack-Retry-Num is %q, want 2", got.Header.Get("X-Slack-Retry-Num"))
+	}
+	if err := InjectSlashCommand(handler, SlashCommand("/bz", "C1", "U1", "stats", "")); err == nil {
+		t.Errorf("a failing endpoint did not return an error")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"sync"
 
+	"github.com/spf13/pflag"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -54,7 +55,7 @@ func NewConfigMapStore(ns, name string) (*configMapStore, error) {
 
 	cm, state, err := s.read()
 	if errors.IsNotFound(err) {
-		s.state = &v1.State{}
+		state = &v1.State{}
 	} else if err != nil {
 		return nil, err
 	}
@@ -96,7 +97,7 @@ func (s *configMapStore) UpdateState(tx func(old *v1.State) (*v1.State, error))
 			return err
 		}
 
-		cm := s.cm
+		cm := s.cm.DeepCopy()
 		if cm == nil {
 			cm = &corev1.ConfigMap{
 				ObjectMeta: metav1.ObjectMeta{
@@ -110,11 +111,14 @@ func (s *configMapStore) UpdateState(tx func(old *v1.State) (*v1.State, error))
 		if err != nil {
 			return fmt.Errorf("failed to encode state while updating: %v", err)
 		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
 		cm.Data["state.yaml"] = string(bs)
 
 		var updated *corev1.ConfigMap
 		updated, lastErr = s.client.CoreV1().ConfigMaps(s.ns).Update(context.TODO(), cm, metav1.UpdateOptions{})
-		if errors.IsNotFound(err) {
+		if errors.IsNotFound(lastErr) {
 			updated, lastErr = s.client.CoreV1().ConfigMaps(s.ns).Create(context.TODO(), cm, metav1.CreateOptions{})
 		}
 		if lastErr != nil && !errors.IsConflict(lastErr) {
@@ -125,7 +129,7 @@ func (s *configMapStore) UpdateState(tx func(old *v1.State) (*v1.State, error))
 			return nil
 		}
 
-		cm, state, err = s.read()
+		s.cm, state, err = s.read()
 		if err != nil {
 			return err
 		}
@@ -141,24 +145,53 @@ func (s *configMapStore) ReadState(process func(*v1.State)) {
 	process(s.state)
 }
 
-var store Store
+type Options struct {
+	ConfigMap string
+}
 
-func UpdateState(tx func(old *v1.State) (*v1.State, error)) error {
-	return store.UpdateState(tx)
+func AddFlags(opt *Options) {
+	pflag.StringVar(&opt.ConfigMap, "state-configmap", "", "Name of the ConfigMap in $NAMESPACE to persist bot state in. Empty disables persistence.")
 }
 
-func ReadState(process func(*v1.State)) {
-	store.ReadState(process)
+// NewStore returns the ConfigMap store configured by the options, or nil if persistence is disabled.
+func NewStore(opt Options) (Store, error) {
+	if len(opt.ConfigMap) == 0 {
+		return nil, nil
+	}
+	s, err := NewConfigMapStore(namespace(), opt.ConfigMap)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
 }
-func init() {
-	ns := "default"
+
+func namespace() string {
 	if env := os.Getenv("NAMESPACE"); env != "" {
-		ns = env
+		return env
 	}
+	return "default"
+}
 
-	var err error
-	store, err = NewConfigMapStore(ns, "state")
-	if err != nil {
-		klog.Fatal(err)
-	}
+var (
+	store     Store
+	storeOnce sync.Once
+)
+
+func defaultStore() Store {
+	storeOnce.Do(func() {
+		var err error
+		store, err = NewConfigMapStore(namespace(), "state")
+		if err != nil {
+			klog.Fatal(err)
+		}
+	})
+	return store
+}
+
+func UpdateState(tx func(old *v1.State) (*v1.State, error)) error {
+	return defaultStore().UpdateState(tx)
+}
+
+func ReadState(process func(*v1.State)) {
+	defaultStore().ReadState(process)
 }
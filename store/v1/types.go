@@ -7,8 +7,43 @@ import (
 type State struct {
 	metav1.TypeMeta `json:",inline"`
 
-	BZStats *BZStats `json:"bzStats"`
+	BZStats     *BZStats     `json:"bzStats"`
+	SlackEvents *SlackEvents `json:"slackEvents,omitempty"`
+	RateLimits  *RateLimits  `json:"rateLimits,omitempty"`
+	RBAC        *RBAC        `json:"rbac,omitempty"`
+
+	// Channels maps channel IDs to their configuration.
+	Channels map[string]ChannelConfig `json:"channels,omitempty"`
+}
+
+// SlackEvents records recently processed Slack event IDs to drop retries across restarts.
+type SlackEvents struct {
+	Seen map[string]metav1.Time `json:"seen,omitempty"`
 }
 
 type BZStats struct {
 }
+
+// RateLimits records the token buckets of the command rate limiter.
+type RateLimits struct {
+	Buckets map[string]TokenBucket `json:"buckets,omitempty"`
+}
+
+type TokenBucket struct {
+	Tokens  float64     `json:"tokens"`
+	Updated metav1.Time `json:"updated"`
+}
+
+// RBAC records roles granted to users via chat, in addition to the configured ones.
+type RBAC struct {
+	// Grants maps role names to user IDs.
+	Grants map[string][]string `json:"grants,omitempty"`
+}
+
+// ChannelConfig records the commands enabled in a channel and their default parameters.
+type ChannelConfig struct {
+	// Commands are the enabled command names. Empty means all commands.
+	Commands []string `json:"commands,omitempty"`
+	// Defaults maps command names to parameter defaults.
+	Defaults map[string]map[string]string `json:"defaults,omitempty"`
+}